@@ -0,0 +1,54 @@
+require 'spec_helper'
+
+RSpec.describe 'Analytics API' do
+  describe 'GET /analytics/reviews' do
+    it 'returns a bucket for every day in the range' do
+      response = APIHelper.get('/analytics/reviews?from=2025-01-01&to=2025-01-07')
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json['bucket']).to eq('day')
+      expect(json['items'].length).to eq(7)
+      expect(json['items'].first).to include('start' => '2025-01-01', 'end' => '2025-01-01')
+      expect(json['items'].first).to include(
+        'reviews',
+        'correct_count',
+        'accuracy',
+        'new_words',
+        'study_sessions',
+        'study_seconds'
+      )
+      expect(json['totals']).to include('start' => '2025-01-01', 'end' => '2025-01-07')
+    end
+
+    it 'counts todays reviews' do
+      session = APIHelper.launch
+      before = JSON.parse(APIHelper.get("/analytics/reviews?group_id=1").body)['totals']['reviews']
+      APIHelper.post("/study_sessions/#{session['id']}/words/1/review", { correct: true }, token: session['launch_token'])
+
+      json = JSON.parse(APIHelper.get("/analytics/reviews?group_id=1").body)
+      expect(json['totals']['reviews']).to eq(before + 1)
+      expect(json['items'].last['reviews']).to be >= 1
+    end
+
+    it 'groups days into weeks starting on Monday' do
+      json = JSON.parse(APIHelper.get('/analytics/reviews?from=2025-01-01&to=2025-01-31&bucket=week').body)
+      expect(json['items'].map { |b| b['start'] }).to eq(%w[2025-01-01 2025-01-06 2025-01-13 2025-01-20 2025-01-27])
+    end
+
+    it 'rejects an unknown bucket' do
+      response = APIHelper.get('/analytics/reviews?bucket=year')
+      expect(response.code).to eq(422)
+    end
+
+    it 'rejects a malformed date' do
+      response = APIHelper.get('/analytics/reviews?from=yesterday')
+      expect(response.code).to eq(400)
+    end
+
+    it 'returns 404 for a non-existent group' do
+      response = APIHelper.get('/analytics/reviews?group_id=999999')
+      expect(response.code).to eq(404)
+    end
+  end
+end
@@ -0,0 +1,30 @@
+require 'spec_helper'
+
+RSpec.describe 'Auth API' do
+  describe 'GET /auth/me' do
+    it 'returns the local learner in single-user mode' do
+      response = APIHelper.get('/auth/me')
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json).to include('id' => 1, 'is_admin' => true)
+      expect(json).to include('username', 'created_at')
+    end
+  end
+
+  describe 'POST /auth/login' do
+    it 'is not available in single-user mode' do
+      response = APIHelper.post('/auth/login', { username: 'local', password: 'password123' })
+      expect(response.code).to eq(404)
+    end
+  end
+
+  describe 'POST /auth/logout' do
+    it 'leaves the local learner signed in in single-user mode' do
+      response = APIHelper.post('/auth/logout')
+      expect(response.code).to eq(404)
+
+      expect(APIHelper.get('/auth/me').code).to eq(200)
+    end
+  end
+end
@@ -1,6 +1,24 @@
 require 'spec_helper'
 
 RSpec.describe 'Dashboard API' do
+  describe 'GET /dashboard/last_study_session' do
+    it 'returns the most recent study session' do
+      session_id = JSON.parse(APIHelper.post('/study_activities', { group_id: 1, study_activity_id: 1 }).body)['id']
+
+      response = APIHelper.get('/dashboard/last_study_session')
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json).to include(
+        'id' => session_id,
+        'group_id' => 1,
+        'study_activity_id' => 1,
+        'group_name' => 'Basic Greetings'
+      )
+      expect(json['created_at']).to match(/^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}/)
+    end
+  end
+
   describe 'GET /dashboard/quick-stats' do
     it 'returns dashboard statistics' do
       response = APIHelper.get('/dashboard/quick-stats')
@@ -8,11 +26,11 @@ RSpec.describe 'Dashboard API' do
 
       json = JSON.parse(response.body)
       expect(json).to include(
-        'total_words',
-        'words_studied',
-        'study_sessions',
-        'accuracy_rate'
+        'success_rate',
+        'total_study_sessions',
+        'total_active_groups',
+        'study_streak_days'
       )
     end
   end
-end 
\ No newline at end of file
+end
@@ -0,0 +1,63 @@
+require 'spec_helper'
+require 'csv'
+
+RSpec.describe 'Export API' do
+  describe 'GET /export/words' do
+    it 'exports words with their stats as CSV' do
+      response = APIHelper.get('/export/words')
+      expect(response.code).to eq(200)
+      expect(response.headers['content-type']).to include('text/csv')
+      expect(response.headers['content-disposition']).to include('words.csv')
+
+      rows = CSV.parse(response.body, headers: true)
+      expect(rows.headers).to include('japanese', 'romaji', 'english', 'groups', 'mastery', 'interval_days')
+      expect(rows.length).to be > 0
+    end
+
+    it 'exports the words of a group as JSON' do
+      response = APIHelper.get('/export/words?format=json&group_id=1')
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json).to include('group_id' => 1, 'group_name' => 'Basic Greetings')
+      expect(json['words'].first).to include('japanese', 'groups', 'correct_count', 'mastery', 'ease_factor')
+      expect(json['words'].map { |word| word['groups'] }).to all(include('Basic Greetings'))
+    end
+
+    it 'exports an Anki deck' do
+      response = APIHelper.get('/export/words?format=apkg&group_id=1')
+      expect(response.code).to eq(200)
+      expect(response.headers['content-disposition']).to include('.apkg')
+      expect(response.body[0, 2]).to eq('PK')
+    end
+
+    it 'rejects an unknown format' do
+      response = APIHelper.get('/export/words?format=xlsx')
+      expect(response.code).to eq(400)
+    end
+
+    it 'returns 404 for a missing group' do
+      response = APIHelper.get('/export/words?group_id=99999')
+      expect(response.code).to eq(404)
+    end
+  end
+
+  describe 'GET /export/reviews' do
+    it 'exports the review log as JSON' do
+      response = APIHelper.get('/export/reviews?format=json')
+      expect(response.code).to eq(200)
+      expect(JSON.parse(response.body)['reviews']).to be_an(Array)
+    end
+
+    it 'exports the review log as CSV' do
+      response = APIHelper.get('/export/reviews')
+      expect(response.code).to eq(200)
+      expect(CSV.parse(response.body, headers: true).headers).to include('created_at', 'word_id', 'correct', 'grade')
+    end
+
+    it 'cannot export reviews as an Anki deck' do
+      response = APIHelper.get('/export/reviews?format=apkg')
+      expect(response.code).to eq(400)
+    end
+  end
+end
@@ -37,6 +37,34 @@ RSpec.describe 'Groups API' do
     end
   end
 
+  describe 'GET /groups?sort_by=last_studied_at' do
+    it 'includes progress stats for each group' do
+      json = JSON.parse(APIHelper.get('/groups?sort_by=last_studied_at&order=desc').body)
+      expect(json['items'].first['stats']).to include('words_studied', 'mastery', 'recent_accuracy')
+    end
+  end
+
+  describe 'GET /groups/:id?recent_sessions=' do
+    it 'measures recent accuracy over the given number of sessions' do
+      group = JSON.parse(APIHelper.post('/groups', { name: "Recent #{Time.now.to_f}" }).body)
+      APIHelper.post("/groups/#{group['id']}/words", { word_ids: [1] })
+      [false, true].each do |correct|
+        session = APIHelper.launch(group['id'])
+        APIHelper.post("/study_sessions/#{session['id']}/words/1/review", { correct: correct }, token: session['launch_token'])
+      end
+
+      stats = JSON.parse(APIHelper.get("/groups/#{group['id']}").body)['stats']
+      expect(stats).to include('recent_accuracy' => 50.0, 'recent_sessions' => 5)
+
+      stats = JSON.parse(APIHelper.get("/groups/#{group['id']}?recent_sessions=1").body)['stats']
+      expect(stats).to include('recent_accuracy' => 100.0, 'recent_sessions' => 1)
+    end
+
+    it 'rejects a non-positive number of sessions' do
+      expect(APIHelper.get('/groups/1?recent_sessions=0').code).to eq(400)
+    end
+  end
+
   describe 'GET /groups/:id' do
     it 'returns a single group with stats' do
       response = APIHelper.get('/groups/1')
@@ -58,6 +86,18 @@ RSpec.describe 'Groups API' do
       expect(json['stats']['total_word_count']).to be_a(Integer)
     end
 
+    it 'reports progress through the group' do
+      session = APIHelper.launch
+      APIHelper.post("/study_sessions/#{session['id']}/words/1/review", { correct: true }, token: session['launch_token'])
+
+      stats = JSON.parse(APIHelper.get('/groups/1').body)['stats']
+      expect(stats).to include('words_studied', 'recent_accuracy', 'last_studied_at', 'estimated_days_to_completion')
+      expect(stats['words_studied']).to be >= 1
+      expect(stats['mastery'].keys).to eq(%w[new learning young mature mastered])
+      expect(stats['mastery'].values.sum).to eq(stats['total_word_count'])
+      expect(stats['last_studied_at']).to match(/^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}/)
+    end
+
     it 'returns 404 for non-existent group' do
       response = APIHelper.get('/groups/999999')
       expect(response.code).to eq(404)
@@ -139,4 +179,93 @@ RSpec.describe 'Groups API' do
       end
     end
   end
-end 
\ No newline at end of file
+
+  describe 'POST /groups/:id/words' do
+    it 'adds words to a group' do
+      response = APIHelper.post('/groups/1/words', { word_ids: [1] })
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json).to include('success', 'added')
+    end
+
+    it 'rejects unknown words' do
+      response = APIHelper.post('/groups/1/words', { word_ids: [999999] })
+      expect(response.code).to eq(422)
+    end
+
+    it 'returns 404 for non-existent group' do
+      response = APIHelper.post('/groups/999999/words', { word_ids: [1] })
+      expect(response.code).to eq(404)
+    end
+  end
+
+  describe 'DELETE /groups/:id/words' do
+    it 'removes words from a group' do
+      APIHelper.post('/groups/2/words', { word_ids: [1] })
+
+      response = APIHelper.delete('/groups/2/words', { word_ids: [1] })
+      expect(response.code).to eq(200)
+      expect(JSON.parse(response.body)['removed']).to eq(1)
+    end
+  end
+
+  describe 'POST /groups' do
+    it 'creates a sub-group and aggregates its words into the parent' do
+      parent = JSON.parse(APIHelper.post('/groups', { name: "Parent #{rand(1_000_000)}" }).body)
+      response = APIHelper.post('/groups', { name: "Child #{rand(1_000_000)}", parent_id: parent['id'] })
+      expect(response.code).to eq(201)
+
+      child = JSON.parse(response.body)
+      expect(child['parent_id']).to eq(parent['id'])
+      APIHelper.post("/groups/#{child['id']}/words", { word_ids: [1] })
+
+      json = JSON.parse(APIHelper.get("/groups/#{parent['id']}?include_descendants=true").body)
+      expect(json['stats']['total_word_count']).to eq(1)
+      expect(json['subgroups'].map { |g| g['id'] }).to include(child['id'])
+    end
+
+    it 'rejects duplicate names' do
+      name = JSON.parse(APIHelper.get('/groups/1').body)['name']
+      response = APIHelper.post('/groups', { name: name })
+      expect(response.code).to eq(409)
+    end
+  end
+
+  describe 'PUT /groups/:id' do
+    it 'rejects making a group its own descendant' do
+      parent = JSON.parse(APIHelper.post('/groups', { name: "Loop #{rand(1_000_000)}" }).body)
+      child = JSON.parse(APIHelper.post('/groups', { name: "Loop child #{rand(1_000_000)}", parent_id: parent['id'] }).body)
+
+      response = APIHelper.put("/groups/#{parent['id']}", { name: parent['name'], parent_id: child['id'] })
+      expect(response.code).to eq(422)
+    end
+  end
+
+  describe 'POST /groups/:id/merge' do
+    it 'moves words into the target group and deletes the source' do
+      source = JSON.parse(APIHelper.post('/groups', { name: "Source #{rand(1_000_000)}" }).body)
+      target = JSON.parse(APIHelper.post('/groups', { name: "Target #{rand(1_000_000)}" }).body)
+      APIHelper.post("/groups/#{source['id']}/words", { word_ids: [1, 2] })
+      APIHelper.post("/groups/#{target['id']}/words", { word_ids: [1] })
+
+      response = APIHelper.post("/groups/#{source['id']}/merge", { target_group_id: target['id'] })
+      expect(response.code).to eq(200)
+      expect(JSON.parse(response.body)['stats']['total_word_count']).to eq(2)
+      expect(APIHelper.get("/groups/#{source['id']}").code).to eq(404)
+    end
+  end
+
+  describe 'DELETE /groups/:id' do
+    it 'deletes an empty group' do
+      group = JSON.parse(APIHelper.post('/groups', { name: "Temp #{rand(1_000_000)}" }).body)
+      response = APIHelper.delete("/groups/#{group['id']}")
+      expect(response.code).to eq(200)
+    end
+
+    it 'returns 404 for non-existent group' do
+      response = APIHelper.delete('/groups/999999')
+      expect(response.code).to eq(404)
+    end
+  end
+end
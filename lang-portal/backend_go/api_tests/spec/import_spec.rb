@@ -0,0 +1,77 @@
+require 'spec_helper'
+require 'tempfile'
+
+RSpec.describe 'Import API' do
+  def csv_file(content, extension = '.csv')
+    file = Tempfile.new(['words', extension])
+    file.write(content)
+    file.rewind
+    file
+  end
+
+  let(:csv) do
+    csv_file(<<~CSV)
+      Japanese,Romaji,English
+      こんにちは,konnichiwa,hello
+      さようなら,sayounara,farewell
+      ねこ,neko,cat
+      ねこ,neko,cat
+      ,inu,dog
+    CSV
+  end
+
+  after do
+    csv.close!
+  end
+
+  describe 'POST /import' do
+    it 'previews rows without writing them' do
+      response = APIHelper.upload('/import', { file: File.open(csv.path), group_id: 1 })
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json['committed']).to eq(false)
+      expect(json['summary']).to include('total' => 5, 'new' => 1, 'duplicate' => 2, 'conflict' => 1, 'error' => 1)
+      expect(json['rows'].map { |row| row['status'] }).to eq(%w[duplicate conflict new duplicate error])
+      expect(json['rows'].last['message']).to include('japanese')
+    end
+
+    it 'commits new words into a new group' do
+      name = "Import #{Time.now.to_i}"
+      response = APIHelper.upload('/import', { file: File.open(csv.path), group: name, commit: 'true' })
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json['committed']).to eq(true)
+      expect(json['group_name']).to eq(name)
+      expect(json['rows'].map { |row| row['action'] }).to eq(%w[linked skipped created linked skipped])
+
+      group = JSON.parse(APIHelper.get("/groups/#{json['group_id']}").body)
+      expect(group['stats']['total_word_count']).to eq(2)
+    end
+
+    it 'maps columns of files without a header' do
+      tsv = csv_file("いぬ\tinu\tdog\n", '.tsv')
+      response = APIHelper.upload('/import', { file: File.open(tsv.path), group_id: 1, has_header: 'false' })
+      expect(response.code).to eq(200)
+      expect(JSON.parse(response.body)['rows'].first).to include('japanese' => 'いぬ', 'english' => 'dog')
+      tsv.close!
+    end
+
+    it 'returns 404 for a missing group' do
+      response = APIHelper.upload('/import', { file: File.open(csv.path), group_id: 99999 })
+      expect(response.code).to eq(404)
+    end
+
+    it 'rejects a mapping onto an unknown column' do
+      response = APIHelper.upload('/import', { file: File.open(csv.path), group_id: 1, mapping: '{"Japanese":"kanji"}' })
+      expect(response.code).to eq(422)
+      expect(JSON.parse(response.body)['field']).to eq('mapping')
+    end
+
+    it 'requires a file' do
+      response = APIHelper.upload('/import', { group_id: 1 })
+      expect(response.code).to eq(400)
+    end
+  end
+end
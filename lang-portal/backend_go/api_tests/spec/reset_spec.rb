@@ -26,6 +26,41 @@ RSpec.describe 'Reset API' do
       progress_json = JSON.parse(progress.body)
       expect(progress_json['total_words_studied']).to eq(0)
     end
+
+    it 'deletes stored xAPI statements' do
+      session = APIHelper.launch
+      APIHelper.post('/xapi/statements', {
+        actor: { mbox: 'mailto:learner@example.com' },
+        verb: { id: 'http://adlnet.gov/expapi/verbs/completed' },
+        object: { id: "http://localhost:8080/api/study_sessions/#{session['id']}" }
+      }, token: session['launch_token'])
+
+      expect(APIHelper.post('/reset_history').code).to eq(200)
+
+      statements = JSON.parse(APIHelper.get('/xapi/statements').body)
+      expect(statements['statements']).to be_empty
+    end
+  end
+
+  describe 'POST /full_reset' do
+    it 'deletes study history and keeps accounts, words, groups and activities' do
+      session = APIHelper.launch
+      APIHelper.post("/study_sessions/#{session['id']}/words/1/review", { correct: true }, token: session['launch_token'])
+
+      response = APIHelper.post('/full_reset')
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json).to include('success' => true, 'message' => 'Study history has been reset for every learner')
+      expect(json['reviews_deleted']).to be >= 1
+      expect(json['study_sessions_deleted']).to be >= 1
+
+      expect(JSON.parse(APIHelper.get('/study_sessions').body)['items']).to be_empty
+      expect(JSON.parse(APIHelper.get('/words').body)['items']).not_to be_empty
+      expect(JSON.parse(APIHelper.get('/groups').body)['items']).not_to be_empty
+      expect(JSON.parse(APIHelper.get('/study_activities').body)['items']).not_to be_empty
+      expect(APIHelper.get('/auth/me').code).to eq(200)
+    end
   end
 
   # ... rest of the file remains the same ...
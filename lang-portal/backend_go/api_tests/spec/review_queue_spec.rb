@@ -0,0 +1,43 @@
+require 'spec_helper'
+
+RSpec.describe 'Review Queue API' do
+  describe 'GET /review_queue' do
+    it 'returns words to study ordered by urgency' do
+      response = APIHelper.get('/review_queue?group_id=1&limit=5')
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json).to include('items')
+      expect(json['items'].length).to be <= 5
+
+      if json['items'].any?
+        item = json['items'].first
+        expect(item).to include(
+          'id',
+          'japanese',
+          'romaji',
+          'english',
+          'is_new',
+          'due_at',
+          'interval_days',
+          'ease_factor'
+        )
+
+        # Type checking
+        expect(item['id']).to be_a(Integer)
+        expect(item['is_new']).to be(true).or be(false)
+        expect(item['interval_days']).to be_a(Integer)
+      end
+    end
+
+    it 'returns 404 for non-existent group' do
+      response = APIHelper.get('/review_queue?group_id=999999')
+      expect(response.code).to eq(404)
+    end
+
+    it 'validates the limit parameter' do
+      response = APIHelper.get('/review_queue?limit=abc')
+      expect(response.code).to eq(400)
+    end
+  end
+end
@@ -0,0 +1,42 @@
+require 'spec_helper'
+
+RSpec.describe 'Settings API' do
+  after do
+    APIHelper.put('/settings', { timezone: 'UTC', daily_goal_reviews: 0, daily_goal_minutes: 0, streak_freezes: 0 })
+  end
+
+  describe 'GET /settings' do
+    it 'returns the settings' do
+      response = APIHelper.get('/settings')
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json).to include('timezone', 'daily_goal_reviews', 'daily_goal_minutes', 'streak_freezes')
+    end
+  end
+
+  describe 'PUT /settings' do
+    it 'updates only the given settings' do
+      response = APIHelper.put('/settings', { timezone: 'Asia/Tokyo', daily_goal_reviews: 20 })
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json).to include('timezone' => 'Asia/Tokyo', 'daily_goal_reviews' => 20, 'streak_freezes' => 0)
+
+      stats = JSON.parse(APIHelper.get('/dashboard/quick-stats').body)
+      expect(stats['today']).to include('goal_reviews' => 20)
+      expect(stats).to include('current_streak_days', 'longest_streak_days', 'studied_today')
+    end
+
+    it 'rejects an unknown timezone' do
+      response = APIHelper.put('/settings', { timezone: 'Mars/Olympus' })
+      expect(response.code).to eq(422)
+      expect(JSON.parse(response.body)['field']).to eq('timezone')
+    end
+
+    it 'rejects a negative goal' do
+      response = APIHelper.put('/settings', { daily_goal_minutes: -5 })
+      expect(response.code).to eq(422)
+    end
+  end
+end
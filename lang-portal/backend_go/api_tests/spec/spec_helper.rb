@@ -9,20 +9,38 @@ module APIHelper
     HTTParty.get(url, body: params.to_json, headers: { 'Content-Type' => 'application/json' })
   end
 
-  def self.post(path, params = {})
+  def self.post(path, params = {}, token: nil)
     url = "http://localhost:8080/api#{path}"
-    HTTParty.post(url, body: params.to_json, headers: { 'Content-Type' => 'application/json' })
+    HTTParty.post(url, body: params.to_json, headers: headers(token))
   end
 
-  def self.put(path, params = {})
+  def self.put(path, params = {}, token: nil)
     url = "http://localhost:8080/api#{path}"
-    HTTParty.put(url, body: params.to_json, headers: { 'Content-Type' => 'application/json' })
+    HTTParty.put(url, body: params.to_json, headers: headers(token))
   end
 
   def self.delete(path, params = {})
     url = "http://localhost:8080/api#{path}"
     HTTParty.delete(url, body: params.to_json, headers: { 'Content-Type' => 'application/json' })
   end
+
+  # Launches a study activity and returns the new session, including the
+  # launch token needed to post reviews into it
+  def self.launch(group_id = 1, study_activity_id = 1)
+    JSON.parse(post('/study_activities', { group_id: group_id, study_activity_id: study_activity_id }).body)
+  end
+
+  def self.headers(token)
+    headers = { 'Content-Type' => 'application/json' }
+    headers['Authorization'] = "Bearer #{token}" if token
+    headers
+  end
+
+  # Posts params as a multipart form; File values are uploaded as files
+  def self.upload(path, params = {})
+    url = "http://localhost:8080/api#{path}"
+    HTTParty.post(url, body: params, multipart: true)
+  end
 end
 
 RSpec.configure do |config|
@@ -1,6 +1,32 @@
 require 'spec_helper'
 
 RSpec.describe 'Study Activities API' do
+  describe 'GET /study_activities' do
+    it 'lists the study activities in the registry' do
+      response = APIHelper.get('/study_activities')
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json).to include('items', 'pagination')
+      expect(json['items'].map { |activity| activity['name'] }).to include('Flashcards', 'Multiple Choice')
+
+      activity = json['items'].first
+      expect(activity).to include('launch_url', 'prompt_types', 'enabled')
+      expect(activity['prompt_types']).to be_an(Array)
+    end
+
+    it 'filters by enabled' do
+      response = APIHelper.get('/study_activities?enabled=true')
+      expect(response.code).to eq(200)
+      expect(JSON.parse(response.body)['items']).to all(include('enabled' => true))
+    end
+
+    it 'returns 400 for an invalid enabled filter' do
+      response = APIHelper.get('/study_activities?enabled=maybe')
+      expect(response.code).to eq(400)
+    end
+  end
+
   describe 'GET /study_activities/:id' do
     it 'returns a specific study activity' do
       response = APIHelper.get('/study_activities/1')
@@ -19,6 +45,9 @@ RSpec.describe 'Study Activities API' do
       expect(json['name']).to be_a(String)
       expect(json['thumbnail_url']).to be_a(String)
       expect(json['description']).to be_a(String)
+      expect(json['launch_url']).to be_a(String)
+      expect(json['prompt_types']).to be_an(Array)
+      expect(json['enabled']).to be(true)
     end
 
     it 'returns 404 for non-existent activity' do
@@ -48,12 +77,78 @@ RSpec.describe 'Study Activities API' do
       # Type checking
       expect(json['id']).to be_a(Integer)
       expect(json['group_id']).to be_a(Integer)
+      expect(json['launch_url']).to include("study_session_id=#{json['id']}")
+      expect(json['launch_token']).to be_a(String)
+      expect(json['launch_url']).to include("launch_token=#{json['launch_token']}")
+      expect(json['launch_token_expires_at']).to match(/^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}/)
     end
 
     it 'validates required parameters' do
       response = APIHelper.post('/study_activities', {})
       expect(response.code).to eq(400)
     end
+
+    it 'returns 422 for a non-existent group' do
+      response = APIHelper.post('/study_activities', { group_id: 999999, study_activity_id: 1 })
+      expect(response.code).to eq(422)
+    end
+
+    it 'returns 422 for a non-existent study activity' do
+      response = APIHelper.post('/study_activities', { group_id: 1, study_activity_id: 999999 })
+      expect(response.code).to eq(422)
+    end
+
+    it 'returns 422 for a disabled study activity' do
+      activity = JSON.parse(APIHelper.post('/study_activities/registry', { name: "Disabled #{Time.now.to_f}", enabled: false }).body)
+      response = APIHelper.post('/study_activities', { group_id: 1, study_activity_id: activity['id'] })
+      expect(response.code).to eq(422)
+    end
+  end
+
+  describe 'registry' do
+    let(:name) { "Writing Practice #{Time.now.to_f}" }
+
+    it 'creates, updates and deletes a study activity' do
+      response = APIHelper.post('/study_activities/registry', {
+        name: name,
+        launch_url: '/writing?session={study_session_id}&group={group_id}',
+        prompt_types: ['en_jp']
+      })
+      expect(response.code).to eq(201)
+      activity = JSON.parse(response.body)
+      expect(activity).to include('name' => name, 'prompt_types' => ['en_jp'], 'enabled' => true)
+
+      response = APIHelper.put("/study_activities/#{activity['id']}", { enabled: false })
+      expect(response.code).to eq(200)
+      expect(JSON.parse(response.body)).to include('name' => name, 'enabled' => false)
+
+      response = APIHelper.delete("/study_activities/#{activity['id']}")
+      expect(response.code).to eq(200)
+      expect(APIHelper.get("/study_activities/#{activity['id']}").code).to eq(404)
+    end
+
+    it 'rejects unknown launch URL placeholders' do
+      response = APIHelper.post('/study_activities/registry', { name: name, launch_url: '/writing?user={user_id}' })
+      expect(response.code).to eq(422)
+      expect(JSON.parse(response.body)['field']).to eq('launch_url')
+    end
+
+    it 'rejects unknown prompt types' do
+      response = APIHelper.post('/study_activities/registry', { name: name, prompt_types: ['kanji_jp'] })
+      expect(response.code).to eq(422)
+      expect(JSON.parse(response.body)['field']).to eq('prompt_types')
+    end
+
+    it 'returns 409 for a duplicate name' do
+      response = APIHelper.post('/study_activities/registry', { name: 'Flashcards' })
+      expect(response.code).to eq(409)
+    end
+
+    it 'does not delete an activity with study sessions' do
+      APIHelper.post('/study_activities', { group_id: 1, study_activity_id: 1 })
+      response = APIHelper.delete('/study_activities/1')
+      expect(response.code).to eq(409)
+    end
   end
 
   describe 'GET /study_activities/:id/study_sessions' do
@@ -26,6 +26,8 @@ RSpec.describe 'Study Sessions API' do
           'group_name',
           'start_time',
           'end_time',
+          'status',
+          'duration_seconds',
           'review_items_count'
         )
 
@@ -66,9 +68,41 @@ RSpec.describe 'Study Sessions API' do
       expect(json['start_time']).to match(/^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}/)
       expect(json['end_time']).to match(/^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}/)
     end
+
+    it 'returns 404 for non-existent study session' do
+      response = APIHelper.get('/study_sessions/999999')
+      expect(response.code).to eq(404)
+    end
+  end
+
+  describe 'GET /study_sessions/:id/words' do
+    it 'lists the reviewed words in review order' do
+      session = APIHelper.launch
+      token = session['launch_token']
+      APIHelper.post("/study_sessions/#{session['id']}/words/2/review", { correct: false }, token: token)
+      APIHelper.post("/study_sessions/#{session['id']}/words/1/review", { correct: true }, token: token)
+      APIHelper.post("/study_sessions/#{session['id']}/words/2/review", { correct: true }, token: token)
+
+      response = APIHelper.get("/study_sessions/#{session['id']}/words")
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json['items'].map { |w| w['id'] }).to eq([2, 1])
+      expect(json['items'].first).to include('correct_count' => 1, 'wrong_count' => 1, 'review_order' => 1)
+      expect(json['items'].first['first_reviewed_at']).to match(/^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}/)
+      expect(json['pagination']['total_items']).to eq(2)
+    end
+
+    it 'returns 404 for non-existent study session' do
+      response = APIHelper.get('/study_sessions/999999/words')
+      expect(response.code).to eq(404)
+    end
   end
 
   describe 'POST /study_sessions/:id/words/:word_id/review' do
+    let(:session) { APIHelper.launch }
+    let(:token) { session['launch_token'] }
+
     let(:valid_params) do
       {
         correct: true
@@ -76,7 +110,7 @@ RSpec.describe 'Study Sessions API' do
     end
 
     it 'records a word review' do
-      response = APIHelper.post('/study_sessions/1/words/1/review', valid_params)
+      response = APIHelper.post("/study_sessions/#{session['id']}/words/1/review", valid_params, token: token)
       expect(response.code).to eq(200)
       
       json = JSON.parse(response.body)
@@ -95,5 +129,154 @@ RSpec.describe 'Study Sessions API' do
       expect(json['correct']).to be true
       expect(json['created_at']).to match(/^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}/)
     end
+
+    it 'records a graded review' do
+      response = APIHelper.post("/study_sessions/#{session['id']}/words/1/review", {
+        grade: 'hard',
+        response_ms: 2400,
+        answer: 'hello',
+        direction: 'jp_en'
+      }, token: token)
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json['correct']).to be true
+      expect(json['grade']).to eq('hard')
+      expect(json['response_ms']).to eq(2400)
+      expect(json['direction']).to eq('jp_en')
+    end
+
+    it 'checks a typed Japanese answer in any script' do
+      ['ohayō gozaimasu', 'ohayou gozaimasu', 'おはようございます', 'ｵﾊﾖｳｺﾞｻﾞｲﾏｽ'].each do |answer|
+        response = APIHelper.post("/study_sessions/#{session['id']}/words/3/review", { answer: answer, direction: 'en_jp' }, token: token)
+        expect(response.code).to eq(200)
+        expect(JSON.parse(response.body)['correct']).to be true
+      end
+    end
+
+    it 'marks a wrong typed Japanese answer as incorrect' do
+      response = APIHelper.post("/study_sessions/#{session['id']}/words/3/review", { answer: 'konbanwa', direction: 'en_jp' }, token: token)
+      expect(JSON.parse(response.body)['correct']).to be false
+    end
+
+    it 'rejects an unknown grade' do
+      response = APIHelper.post("/study_sessions/#{session['id']}/words/1/review", { grade: 'perfect' }, token: token)
+      expect(response.code).to eq(400)
+    end
+
+    it 'requires the correct flag' do
+      response = APIHelper.post("/study_sessions/#{session['id']}/words/1/review", {}, token: token)
+      expect(response.code).to eq(400)
+    end
+
+    it 'requires a launch token' do
+      response = APIHelper.post("/study_sessions/#{session['id']}/words/1/review", valid_params)
+      expect(response.code).to eq(401)
+    end
+
+    it 'rejects an invalid launch token' do
+      response = APIHelper.post("/study_sessions/#{session['id']}/words/1/review", valid_params, token: "#{token}x")
+      expect(response.code).to eq(401)
+    end
+
+    it 'rejects a launch token for another session' do
+      response = APIHelper.post("/study_sessions/#{APIHelper.launch['id']}/words/1/review", valid_params, token: token)
+      expect(response.code).to eq(403)
+    end
+
+    it 'returns 404 for a non-existent word' do
+      response = APIHelper.post("/study_sessions/#{session['id']}/words/999999/review", valid_params, token: token)
+      expect(response.code).to eq(404)
+    end
+  end
+
+  describe 'POST /study_sessions/:id/words/:word_id/answer' do
+    let(:session) { APIHelper.launch }
+    let(:token) { session['launch_token'] }
+
+    it 'accepts the meaning of a word' do
+      response = APIHelper.post("/study_sessions/#{session['id']}/words/1/answer", { answer: 'Hello!', direction: 'jp_en' }, token: token)
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json).to include('correct', 'verdict', 'expected', 'accepted_answers', 'review')
+      expect(json['verdict']).to eq('correct')
+      expect(json['review']['grade']).to eq('good')
+    end
+
+    it 'tolerates a small typo' do
+      json = JSON.parse(APIHelper.post("/study_sessions/#{session['id']}/words/1/answer", { answer: 'konichiwa', direction: 'en_jp' }, token: token).body)
+      expect(json['correct']).to be true
+      expect(json['verdict']).to eq('typo')
+      expect(json['review']['grade']).to eq('hard')
+    end
+
+    it 'grades a wrong vowel length as a typo rather than correct' do
+      word = JSON.parse(APIHelper.post('/words', { japanese: 'ゆき', romaji: 'yuki', english: 'snow' }).body)
+      APIHelper.post('/groups/1/words', { word_ids: [word['id']] })
+
+      ['yuuki', 'ゆうき'].each do |answer|
+        json = JSON.parse(APIHelper.post("/study_sessions/#{session['id']}/words/#{word['id']}/answer", { answer: answer, direction: 'en_jp' }, token: token).body)
+        expect(json['verdict']).to eq('typo'), "expected #{answer} to be graded as a typo"
+        expect(json['review']['grade']).to eq('hard')
+      end
+
+      APIHelper.delete("/words/#{word['id']}")
+    end
+
+    it 'rejects a wrong answer and returns the expected one' do
+      json = JSON.parse(APIHelper.post("/study_sessions/#{session['id']}/words/1/answer", { answer: 'goodbye', direction: 'jp_en' }, token: token).body)
+      expect(json['correct']).to be false
+      expect(json['expected']).to eq('hello')
+    end
+
+    it 'requires a launch token' do
+      response = APIHelper.post("/study_sessions/#{session['id']}/words/1/answer", { answer: 'hello', direction: 'jp_en' })
+      expect(response.code).to eq(401)
+    end
+
+    it 'requires an answer' do
+      response = APIHelper.post("/study_sessions/#{session['id']}/words/1/answer", {}, token: token)
+      expect(response.code).to eq(400)
+    end
+  end
+
+  describe 'session lifecycle' do
+    let(:session) { APIHelper.launch }
+    let(:session_id) { session['id'] }
+
+    it 'keeps a session active with heartbeats' do
+      response = APIHelper.post("/study_sessions/#{session_id}/heartbeat")
+      expect(response.code).to eq(200)
+      expect(JSON.parse(response.body)['status']).to eq('active')
+    end
+
+    it 'renews the launch token on a heartbeat with it' do
+      response = APIHelper.post("/study_sessions/#{session_id}/heartbeat", {}, token: session['launch_token'])
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json).to include('launch_token', 'launch_token_expires_at')
+      review = APIHelper.post("/study_sessions/#{session_id}/words/1/review", { correct: true }, token: json['launch_token'])
+      expect(review.code).to eq(200)
+    end
+
+    it 'ends a session and refuses further reviews' do
+      response = APIHelper.post("/study_sessions/#{session_id}/end")
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json['status']).to eq('completed')
+      expect(json['duration_seconds']).to be >= 0
+
+      expect(APIHelper.post("/study_sessions/#{session_id}/end").code).to eq(409)
+      review = APIHelper.post("/study_sessions/#{session_id}/words/1/review", { correct: true }, token: session['launch_token'])
+      expect(review.code).to eq(409)
+    end
+
+    it 'returns 404 for a non-existent study session' do
+      response = APIHelper.post('/study_sessions/999999/end')
+      expect(response.code).to eq(404)
+    end
   end
-end 
\ No newline at end of file
+end
@@ -16,6 +16,83 @@ RSpec.describe 'Words API' do
     end
   end
 
+  describe 'GET /words?mastery=' do
+    it 'lists only words at the given mastery level' do
+      session = APIHelper.launch
+      APIHelper.post("/study_sessions/#{session['id']}/words/1/review", { correct: true }, token: session['launch_token'])
+
+      json = JSON.parse(APIHelper.get('/words?mastery=learning').body)
+      expect(json['items'].map { |w| w['id'] }).to include(1)
+      expect(json['items'].map { |w| w['mastery'] }.uniq).to eq(['learning'])
+    end
+
+    it 'rejects an unknown mastery level' do
+      response = APIHelper.get('/words?mastery=expert')
+      expect(response.code).to eq(400)
+    end
+  end
+
+  describe 'GET /words/leeches' do
+    it 'lists words that have been failed repeatedly' do
+      session = APIHelper.launch
+      4.times { APIHelper.post("/study_sessions/#{session['id']}/words/2/review", { correct: false }, token: session['launch_token']) }
+
+      response = APIHelper.get('/words/leeches?group_id=1')
+      expect(response.code).to eq(200)
+
+      leech = JSON.parse(response.body)['items'].find { |w| w['id'] == 2 }
+      expect(leech).to include('leech' => true)
+      expect(leech['lapses']).to be >= 4
+    end
+
+    it 'returns 404 for a non-existent group' do
+      response = APIHelper.get('/words/leeches?group_id=999999')
+      expect(response.code).to eq(404)
+    end
+  end
+
+  describe 'GET /words?q=' do
+    it 'finds words by romaji prefix with highlighting' do
+      response = APIHelper.get('/words?q=konn')
+      expect(response.code).to eq(200)
+
+      item = JSON.parse(response.body)['items'].first
+      expect(item['romaji']).to eq('konnichiwa')
+      expect(item['match']).to eq('romaji')
+      expect(item['highlight']['romaji']).to include('<mark>')
+    end
+
+    it 'finds words by japanese substring' do
+      json = JSON.parse(APIHelper.get("/words?q=#{URI.encode_www_form_component('にち')}").body)
+      expect(json['items'].map { |w| w['japanese'] }).to include('こんにちは')
+    end
+
+    it 'finds words by stemmed english' do
+      json = JSON.parse(APIHelper.get('/words?q=mornings').body)
+      expect(json['items'].map { |w| w['english'] }).to include('good morning')
+    end
+
+    it 'tolerates romaji typos' do
+      json = JSON.parse(APIHelper.get('/words?q=konichiwa').body)
+      expect(json['items'].first['romaji']).to eq('konnichiwa')
+      expect(json['items'].first['match']).to eq('fuzzy')
+    end
+
+    it 'applies the mastery filter to search results' do
+      json = JSON.parse(APIHelper.get('/words?q=o&mastery=new').body)
+      expect(json['items']).not_to be_empty
+      expect(json['items'].map { |w| w['mastery'] }.uniq).to eq(['new'])
+      expect(json['pagination']['total_items']).to eq(json['items'].length)
+    end
+
+    it 'sorts search results when asked' do
+      json = JSON.parse(APIHelper.get('/words?q=s&sort_by=romaji&order=asc').body)
+      romaji = json['items'].map { |w| w['romaji'] }
+      expect(romaji.length).to be > 1
+      expect(romaji).to eq(romaji.sort)
+    end
+  end
+
   describe 'GET /words/:id' do
     it 'returns a specific word' do
       # Reset and initialize test data
@@ -36,9 +113,141 @@ RSpec.describe 'Words API' do
       )
     end
 
+    it 'returns the parts of seeded words' do
+      json = JSON.parse(APIHelper.get('/words/4').body)
+      expect(json['parts']).to be_an(Array)
+      expect(json['parts'].first).to include('kanji', 'on', 'kun', 'romaji')
+    end
+
     it 'returns 404 for non-existent word' do
       response = APIHelper.get('/words/999')
       expect(response.code).to eq(404)
     end
   end
-end 
\ No newline at end of file
+
+  describe 'POST /words' do
+    it 'creates a word' do
+      response = APIHelper.post('/words', { japanese: 'ありがとう', romaji: 'arigatou', english: 'thank you' })
+      expect(response.code).to eq(201)
+
+      json = JSON.parse(response.body)
+      expect(json).to include('id', 'japanese', 'romaji', 'english')
+      expect(json['id']).to be_a(Integer)
+    end
+
+    it 'stores the parts of a word' do
+      parts = [
+        { kanji: '飲', on: ['イン'], kun: ['の.む'], romaji: ['no'] },
+        { kanji: 'む', romaji: ['mu'] }
+      ]
+      response = APIHelper.post('/words', { japanese: '飲む', romaji: 'nomu', english: 'to drink', parts: parts })
+      expect(response.code).to eq(201)
+
+      json = JSON.parse(APIHelper.get("/words/#{JSON.parse(response.body)['id']}").body)
+      expect(json['parts'].map { |p| p['kanji'] }).to eq(['飲', 'む'])
+    end
+
+    it 'rejects parts that do not spell out the word' do
+      response = APIHelper.post('/words', { japanese: '書く', romaji: 'kaku', english: 'to write',
+                                            parts: [{ kanji: '書', romaji: ['ka'] }] })
+      expect(response.code).to eq(422)
+    end
+
+    it 'fills in the romaji of a kana word' do
+      response = APIHelper.post('/words', { japanese: 'ｺｰﾋｰ', english: 'coffee' })
+      expect(response.code).to eq(201)
+
+      json = JSON.parse(response.body)
+      expect(json['japanese']).to eq('コーヒー')
+      expect(json['romaji']).to eq('koohii')
+    end
+
+    it 'rejects a duplicate word' do
+      APIHelper.post('/words', { japanese: 'すみません', romaji: 'sumimasen', english: 'excuse me' })
+      response = APIHelper.post('/words', { japanese: 'すみません', romaji: 'sumimasen', english: 'sorry' })
+      expect(response.code).to eq(409)
+    end
+
+    it 'rejects romaji that does not match the kana' do
+      response = APIHelper.post('/words', { japanese: 'いいえ', romaji: 'hai', english: 'no' })
+      expect(response.code).to eq(422)
+    end
+
+    it 'rejects romaji that differs from the kana in vowel length or syllables' do
+      [['ゆき', 'yuuki'], ['おばあさん', 'obasan'], ['ビル', 'biiru'], ['きんえん', 'kinen']].each do |japanese, romaji|
+        response = APIHelper.post('/words', { japanese: japanese, romaji: romaji, english: 'test' })
+        expect(response.code).to eq(422), "expected #{romaji} to be rejected for #{japanese}"
+        expect(JSON.parse(response.body)['field']).to eq('romaji')
+      end
+    end
+
+    it 'accepts the same long vowel written in different ways' do
+      response = APIHelper.post('/words', { japanese: 'おおきい', romaji: 'ōkii', english: 'big' })
+      expect(response.code).to eq(201)
+
+      response = APIHelper.post('/words', { japanese: 'きんえん', romaji: "kin'en", english: 'no smoking' })
+      expect(response.code).to eq(201)
+    end
+
+    it 'accepts Kunrei-shiki and traditional Hepburn romaji' do
+      response = APIHelper.post('/words', { japanese: 'しんぶん', romaji: 'simbun', english: 'newspaper' })
+      expect(response.code).to eq(201)
+
+      response = APIHelper.post('/words', { japanese: 'ちず', romaji: 'tizu', english: 'map' })
+      expect(response.code).to eq(201)
+    end
+
+    it 'rejects romaji of a kanji word that does not read as kana' do
+      ['xyz', 'hello', 'mba'].each do |romaji|
+        response = APIHelper.post('/words', { japanese: '食べる', romaji: romaji, english: 'to eat' })
+        expect(response.code).to eq(422), "expected #{romaji} to be rejected"
+        expect(JSON.parse(response.body)['field']).to eq('romaji')
+      end
+    end
+
+    it 'rejects romaji with a short vowel for a long one' do
+      response = APIHelper.post('/words', { japanese: 'おじいさん', romaji: 'ojisan', english: 'grandfather' })
+      expect(response.code).to eq(422)
+      expect(JSON.parse(response.body)['field']).to eq('romaji')
+    end
+
+    it 'rejects romaji that does not match the parts' do
+      response = APIHelper.post('/words', { japanese: '書く', romaji: 'taberu', english: 'to write',
+                                            parts: [{ kanji: '書', romaji: ['ka'] }, { kanji: 'く', romaji: ['ku'] }] })
+      expect(response.code).to eq(422)
+      expect(JSON.parse(response.body)['field']).to eq('romaji')
+    end
+
+    it 'requires japanese' do
+      response = APIHelper.post('/words', { japanese: '', romaji: 'hai', english: 'yes' })
+      expect(response.code).to eq(422)
+    end
+  end
+
+  describe 'PUT /words/:id' do
+    it 'updates a word' do
+      created = JSON.parse(APIHelper.post('/words', { japanese: 'はい', romaji: 'hai', english: 'yes' }).body)
+
+      response = APIHelper.put("/words/#{created['id']}", { japanese: 'はい', romaji: 'hai', english: 'yes (polite)' })
+      expect(response.code).to eq(200)
+      expect(JSON.parse(response.body)['english']).to eq('yes (polite)')
+    end
+
+    it 'returns 404 for non-existent word' do
+      response = APIHelper.put('/words/999999', { japanese: 'はい', romaji: 'hai', english: 'yes' })
+      expect(response.code).to eq(404)
+    end
+  end
+
+  describe 'DELETE /words/:id' do
+    it 'deletes a word' do
+      created = JSON.parse(APIHelper.post('/words', { japanese: 'みず', romaji: 'mizu', english: 'water' }).body)
+
+      response = APIHelper.delete("/words/#{created['id']}")
+      expect(response.code).to eq(200)
+
+      response = APIHelper.get("/words/#{created['id']}")
+      expect(response.code).to eq(404)
+    end
+  end
+end
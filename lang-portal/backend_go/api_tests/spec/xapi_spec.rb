@@ -0,0 +1,134 @@
+require 'spec_helper'
+require 'securerandom'
+require 'cgi'
+
+RSpec.describe 'xAPI API' do
+  let(:session) { APIHelper.launch }
+  let(:session_id) { session['id'] }
+  let(:token) { session['launch_token'] }
+
+  def answered(session_id, word_id, success, id: nil)
+    statement = {
+      actor: { mbox: 'mailto:learner@example.com' },
+      verb: { id: 'http://adlnet.gov/expapi/verbs/answered' },
+      object: { id: "http://localhost:8080/api/words/#{word_id}" },
+      result: { success: success, response: 'hello', duration: 'PT2S' },
+      context: { contextActivities: { parent: { id: "http://localhost:8080/api/study_sessions/#{session_id}" } } }
+    }
+    statement[:id] = id if id
+    statement
+  end
+
+  def session_words(session_id)
+    JSON.parse(APIHelper.get("/study_sessions/#{session_id}/words").body)['items']
+  end
+
+  describe 'POST /xapi/statements' do
+    it 'records answered statements as reviews and returns their ids' do
+      response = APIHelper.post('/xapi/statements', [answered(session_id, 1, true), answered(session_id, 2, false)], token: token)
+      expect(response.code).to eq(200)
+      expect(response.headers['x-experience-api-version']).to eq('1.0.3')
+
+      ids = JSON.parse(response.body)
+      expect(ids.length).to eq(2)
+
+      words = session_words(session_id)
+      expect(words.map { |word| word['id'] }).to eq([1, 2])
+      expect(words.first['correct_count']).to eq(1)
+    end
+
+    it 'ignores a resent statement and rejects a conflicting one' do
+      id = SecureRandom.uuid
+      expect(APIHelper.post('/xapi/statements', answered(session_id, 1, true, id: id), token: token).code).to eq(200)
+      expect(APIHelper.post('/xapi/statements', answered(session_id, 1, true, id: id), token: token).code).to eq(200)
+      expect(session_words(session_id).first['correct_count']).to eq(1)
+
+      response = APIHelper.post('/xapi/statements', answered(session_id, 1, false, id: id), token: token)
+      expect(response.code).to eq(409)
+    end
+
+    it 'voids a statement and removes its review' do
+      id = SecureRandom.uuid
+      APIHelper.post('/xapi/statements', answered(session_id, 1, true, id: id), token: token)
+
+      response = APIHelper.post('/xapi/statements', {
+        actor: { mbox: 'mailto:learner@example.com' },
+        verb: { id: 'http://adlnet.gov/expapi/verbs/voided' },
+        object: { objectType: 'StatementRef', id: id }
+      }, token: token)
+      expect(response.code).to eq(200)
+      expect(session_words(session_id)).to be_empty
+
+      expect(APIHelper.get("/xapi/statements?statementId=#{id}").code).to eq(404)
+      expect(APIHelper.get("/xapi/statements?voidedStatementId=#{id}").code).to eq(200)
+    end
+
+    it 'ends a session on a completed statement' do
+      response = APIHelper.post('/xapi/statements', {
+        actor: { mbox: 'mailto:learner@example.com' },
+        verb: { id: 'http://adlnet.gov/expapi/verbs/completed' },
+        object: { id: "http://localhost:8080/api/study_sessions/#{session_id}" }
+      }, token: token)
+      expect(response.code).to eq(200)
+      expect(JSON.parse(APIHelper.get("/study_sessions/#{session_id}").body)['status']).to eq('completed')
+    end
+
+    it 'requires a launch token for statements about a session' do
+      response = APIHelper.post('/xapi/statements', answered(session_id, 1, true))
+      expect(response.code).to eq(401)
+      expect(session_words(session_id)).to be_empty
+
+      other = APIHelper.launch
+      response = APIHelper.post('/xapi/statements', answered(session_id, 1, true), token: other['launch_token'])
+      expect(response.code).to eq(403)
+    end
+
+    it 'rejects an answered statement without a session' do
+      statement = answered(session_id, 1, true)
+      statement.delete(:context)
+      response = APIHelper.post('/xapi/statements', statement, token: token)
+      expect(response.code).to eq(400)
+      expect(JSON.parse(response.body)['field']).to eq('context.contextActivities')
+    end
+
+    it 'rejects a statement without an actor' do
+      response = APIHelper.post('/xapi/statements', {
+        verb: { id: 'http://adlnet.gov/expapi/verbs/experienced' },
+        object: { id: 'https://example.com/songs/1' }
+      })
+      expect(response.code).to eq(400)
+      expect(JSON.parse(response.body)['field']).to eq('actor')
+    end
+  end
+
+  describe 'PUT /xapi/statements' do
+    it 'stores a statement under the given id' do
+      id = SecureRandom.uuid
+      statement = {
+        actor: { account: { homePage: 'https://example.com', name: 'learner' } },
+        verb: { id: 'http://adlnet.gov/expapi/verbs/experienced' },
+        object: { id: 'https://example.com/songs/1' }
+      }
+      expect(APIHelper.put("/xapi/statements?statementId=#{id}", statement).code).to eq(204)
+
+      json = JSON.parse(APIHelper.get("/xapi/statements?statementId=#{id}").body)
+      expect(json).to include('stored', 'timestamp', 'id' => id, 'version' => '1.0.3')
+    end
+  end
+
+  describe 'GET /xapi/statements' do
+    it 'filters statements by verb and agent' do
+      APIHelper.post('/xapi/statements', answered(session_id, 1, true), token: token)
+
+      verb = CGI.escape('http://adlnet.gov/expapi/verbs/answered')
+      agent = CGI.escape({ mbox: 'mailto:learner@example.com' }.to_json)
+      response = APIHelper.get("/xapi/statements?verb=#{verb}&agent=#{agent}&limit=1")
+      expect(response.code).to eq(200)
+
+      json = JSON.parse(response.body)
+      expect(json['statements'].length).to eq(1)
+      expect(json['statements'].first['verb']['id']).to eq('http://adlnet.gov/expapi/verbs/answered')
+      expect(json).to include('more')
+    end
+  end
+end
@@ -3,9 +3,13 @@ package main
 import (
 	"log"
 	"os"
+	"time"
+	// Embed the zone database so learner timezones work on hosts without one
+	_ "time/tzdata"
 	"github.com/gin-gonic/gin"
 	"github.com/mohawa/lang-portal/backend_go/internal/database"
 	"github.com/mohawa/lang-portal/backend_go/internal/handlers"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
 )
 
 func main() {
@@ -21,6 +25,47 @@ func main() {
 	}
 	defer db.Close()
 
+	// Close study sessions nobody has worked on for a while
+	idleTimeout := 30 * time.Minute
+	if value := os.Getenv("SESSION_IDLE_TIMEOUT"); value != "" {
+		if idleTimeout, err = time.ParseDuration(value); err != nil || idleTimeout <= 0 {
+			log.Fatalf("Invalid SESSION_IDLE_TIMEOUT %q", value)
+		}
+	}
+	stopSweeper := services.StartSessionSweeper(min(time.Minute, idleTimeout), idleTimeout)
+	defer stopSweeper()
+
+	// Sign the tokens launched study apps post results with
+	launchTokenTTL := 2 * time.Hour
+	if value := os.Getenv("LAUNCH_TOKEN_TTL"); value != "" {
+		if launchTokenTTL, err = time.ParseDuration(value); err != nil || launchTokenTTL <= 0 {
+			log.Fatalf("Invalid LAUNCH_TOKEN_TTL %q", value)
+		}
+	}
+	launchSecret := os.Getenv("LAUNCH_TOKEN_SECRET")
+	if launchSecret == "" {
+		log.Printf("LAUNCH_TOKEN_SECRET is not set; launch tokens will not survive a restart")
+	}
+	services.ConfigureLaunchTokens(launchSecret, launchTokenTTL)
+
+	// Everyone studies as the local learner unless AUTH_MODE=multi gives
+	// every learner an account
+	multiUser := false
+	switch authMode := os.Getenv("AUTH_MODE"); authMode {
+	case "", "single":
+	case "multi":
+		multiUser = true
+	default:
+		log.Fatalf("Invalid AUTH_MODE %q; use single or multi", authMode)
+	}
+	authSessionTTL := 30 * 24 * time.Hour
+	if value := os.Getenv("AUTH_SESSION_TTL"); value != "" {
+		if authSessionTTL, err = time.ParseDuration(value); err != nil || authSessionTTL <= 0 {
+			log.Fatalf("Invalid AUTH_SESSION_TTL %q", value)
+		}
+	}
+	services.ConfigureAuth(authSessionTTL)
+
 	r := gin.Default()
 
 	// CORS middleware
@@ -36,40 +81,97 @@ func main() {
 	})
 
 	// API routes
-	api := r.Group("/api")
+	api := r.Group("/api", handlers.Authenticate(multiUser))
 	{
 		// Test routes (only in test environment)
 		if os.Getenv("APP_ENV") == "test" {
 			api.POST("/test/init_data", handlers.InitTestData)
 		}
 
+		// Account routes (only in multi-user mode)
+		if multiUser {
+			api.POST("/auth/register", handlers.Register)
+			api.POST("/auth/login", handlers.Login)
+			api.POST("/auth/logout", handlers.Logout)
+		}
+
+		// Everything else needs a learner, apart from the routes a launched
+		// study app posts results to with its launch token. Changing the
+		// shared words, groups and study activities takes an administrator.
+		learner := api.Group("", handlers.RequireLearner)
+		admin := learner.Group("", handlers.RequireAdmin)
+
+		learner.GET("/auth/me", handlers.GetCurrentUser)
+
 		// Words routes
-		api.GET("/words", handlers.GetWords)
-		api.GET("/words/:id", handlers.GetWord)
+		learner.GET("/words", handlers.GetWords)
+		learner.GET("/words/leeches", handlers.GetLeeches)
+		learner.GET("/words/:id", handlers.GetWord)
+		admin.POST("/words", handlers.CreateWord)
+		admin.PUT("/words/:id", handlers.UpdateWord)
+		admin.DELETE("/words/:id", handlers.DeleteWord)
 
 		// Groups routes
-		api.GET("/groups", handlers.GetGroups)
-		api.GET("/groups/:id", handlers.GetGroup)
-		api.GET("/groups/:id/words", handlers.GetGroupWords)
-		api.GET("/groups/:id/study_sessions", handlers.GetGroupStudySessions)
+		learner.GET("/groups", handlers.GetGroups)
+		learner.GET("/groups/:id", handlers.GetGroup)
+		admin.POST("/groups", handlers.CreateGroup)
+		admin.PUT("/groups/:id", handlers.UpdateGroup)
+		admin.DELETE("/groups/:id", handlers.DeleteGroup)
+		admin.POST("/groups/:id/merge", handlers.MergeGroup)
+		learner.GET("/groups/:id/words", handlers.GetGroupWords)
+		admin.POST("/groups/:id/words", handlers.AddGroupWords)
+		admin.DELETE("/groups/:id/words", handlers.RemoveGroupWords)
+		learner.GET("/groups/:id/study_sessions", handlers.GetGroupStudySessions)
 
 		// Study sessions routes
-		api.GET("/study_sessions", handlers.GetStudySessions)
-		api.GET("/study_sessions/:id", handlers.GetStudySession)
+		learner.GET("/study_sessions", handlers.GetStudySessions)
+		learner.GET("/study_sessions/:id", handlers.GetStudySession)
+		learner.GET("/study_sessions/:id/words", handlers.GetStudySessionWords)
+		api.POST("/study_sessions/:id/heartbeat", handlers.HeartbeatStudySession)
+		api.POST("/study_sessions/:id/end", handlers.EndStudySession)
 		api.POST("/study_sessions/:id/words/:word_id/review", handlers.ReviewWord)
+		api.POST("/study_sessions/:id/words/:word_id/answer", handlers.AnswerWord)
+
+		// Spaced repetition routes
+		learner.GET("/review_queue", handlers.GetReviewQueue)
 
 		// Study activities routes
-		api.GET("/study_activities/:id", handlers.GetStudyActivity)
-		api.GET("/study_activities/:id/study_sessions", handlers.GetStudyActivitySessions)
-		api.POST("/study_activities", handlers.CreateStudyActivity)
+		learner.GET("/study_activities", handlers.GetStudyActivities)
+		learner.GET("/study_activities/:id", handlers.GetStudyActivity)
+		learner.GET("/study_activities/:id/study_sessions", handlers.GetStudyActivitySessions)
+		learner.POST("/study_activities", handlers.CreateStudyActivity)
+		admin.POST("/study_activities/registry", handlers.RegisterStudyActivity)
+		admin.PUT("/study_activities/:id", handlers.UpdateStudyActivity)
+		admin.DELETE("/study_activities/:id", handlers.DeleteStudyActivity)
 
 		// Dashboard routes
-		api.GET("/dashboard/quick-stats", handlers.GetQuickStats)
-		api.GET("/dashboard/study_progress", handlers.GetStudyProgress)
+		learner.GET("/dashboard/last_study_session", handlers.GetLastStudySession)
+		learner.GET("/dashboard/quick-stats", handlers.GetQuickStats)
+		learner.GET("/dashboard/study_progress", handlers.GetStudyProgress)
+
+		// Analytics routes
+		learner.GET("/analytics/reviews", handlers.GetReviewAnalytics)
+
+		// Import routes
+		admin.POST("/import", handlers.ImportWords)
+
+		// Export routes
+		learner.GET("/export/words", handlers.ExportWords)
+		learner.GET("/export/reviews", handlers.ExportReviews)
+
+		// xAPI learning record store routes
+		xapi := api.Group("/xapi", handlers.XAPIVersionHeader)
+		xapi.POST("/statements", handlers.PostXAPIStatements)
+		xapi.PUT("/statements", handlers.PutXAPIStatement)
+		xapi.GET("/statements", handlers.RequireLearner, handlers.GetXAPIStatements)
+
+		// Settings routes
+		learner.GET("/settings", handlers.GetSettings)
+		learner.PUT("/settings", handlers.UpdateSettings)
 
 		// Reset routes
-		api.POST("/reset_history", handlers.ResetHistory)
-		api.POST("/full_reset", handlers.FullReset)
+		learner.POST("/reset_history", handlers.ResetHistory)
+		admin.POST("/full_reset", handlers.FullReset)
 	}
 
 	log.Printf("Server starting on http://localhost:8080")
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS word_review_items;
+DROP TABLE IF EXISTS study_sessions;
+DROP TABLE IF EXISTS study_activities;
+DROP TABLE IF EXISTS words_groups;
+DROP TABLE IF EXISTS groups;
+DROP TABLE IF EXISTS words;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_word_schedules_due_at;
+DROP TABLE IF EXISTS word_schedules;
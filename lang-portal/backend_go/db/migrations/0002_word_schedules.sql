@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS word_schedules (
+    word_id INTEGER PRIMARY KEY,
+    ease_factor REAL NOT NULL DEFAULT 2.5,
+    interval_days INTEGER NOT NULL DEFAULT 0,
+    repetitions INTEGER NOT NULL DEFAULT 0,
+    lapses INTEGER NOT NULL DEFAULT 0,
+    due_at DATETIME NOT NULL,
+    last_reviewed_at DATETIME,
+    FOREIGN KEY (word_id) REFERENCES words(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_word_schedules_due_at ON word_schedules(due_at);
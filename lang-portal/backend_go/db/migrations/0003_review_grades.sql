@@ -0,0 +1,4 @@
+ALTER TABLE word_review_items ADD COLUMN grade TEXT CHECK (grade IN ('again', 'hard', 'good', 'easy'));
+ALTER TABLE word_review_items ADD COLUMN response_ms INTEGER;
+ALTER TABLE word_review_items ADD COLUMN answer TEXT;
+ALTER TABLE word_review_items ADD COLUMN direction TEXT CHECK (direction IN ('jp_en', 'en_jp', 'audio_jp'));
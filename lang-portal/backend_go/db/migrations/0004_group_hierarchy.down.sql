@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_words_groups_word_id;
+DROP INDEX IF EXISTS idx_words_groups_group_id;
+DROP INDEX IF EXISTS idx_groups_parent_id;
+
+-- parent_id is part of a foreign key, so it cannot be dropped in place
+CREATE TABLE groups_without_parent (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL
+);
+INSERT INTO groups_without_parent (id, name) SELECT id, name FROM groups;
+DROP TABLE groups;
+ALTER TABLE groups_without_parent RENAME TO groups;
@@ -0,0 +1,5 @@
+ALTER TABLE groups ADD COLUMN parent_id INTEGER REFERENCES groups(id);
+
+CREATE INDEX IF NOT EXISTS idx_groups_parent_id ON groups(parent_id);
+CREATE INDEX IF NOT EXISTS idx_words_groups_group_id ON words_groups(group_id);
+CREATE INDEX IF NOT EXISTS idx_words_groups_word_id ON words_groups(word_id);
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS words_fts_update;
+DROP TRIGGER IF EXISTS words_fts_delete;
+DROP TRIGGER IF EXISTS words_fts_insert;
+
+DROP TABLE IF EXISTS words_japanese_fts;
+DROP TABLE IF EXISTS words_english_fts;
+DROP TABLE IF EXISTS words_romaji_fts;
@@ -0,0 +1,50 @@
+-- Full-text indexes over words. FTS5 is only compiled into go-sqlite3 when
+-- building with -tags sqlite_fts5.
+--
+-- Each field needs a different tokenizer, so each gets its own index:
+-- romaji is matched by prefix, english is stemmed and japanese is matched
+-- by substring through trigrams.
+CREATE VIRTUAL TABLE words_romaji_fts USING fts5(
+    romaji,
+    content='words', content_rowid='id',
+    tokenize='unicode61 remove_diacritics 2',
+    prefix='2 3'
+);
+
+CREATE VIRTUAL TABLE words_english_fts USING fts5(
+    english,
+    content='words', content_rowid='id',
+    tokenize='porter unicode61 remove_diacritics 2'
+);
+
+CREATE VIRTUAL TABLE words_japanese_fts USING fts5(
+    japanese,
+    content='words', content_rowid='id',
+    tokenize='trigram'
+);
+
+CREATE TRIGGER words_fts_insert AFTER INSERT ON words BEGIN
+    INSERT INTO words_romaji_fts (rowid, romaji) VALUES (new.id, new.romaji);
+    INSERT INTO words_english_fts (rowid, english) VALUES (new.id, new.english);
+    INSERT INTO words_japanese_fts (rowid, japanese) VALUES (new.id, new.japanese);
+END;
+
+CREATE TRIGGER words_fts_delete AFTER DELETE ON words BEGIN
+    INSERT INTO words_romaji_fts (words_romaji_fts, rowid, romaji) VALUES ('delete', old.id, old.romaji);
+    INSERT INTO words_english_fts (words_english_fts, rowid, english) VALUES ('delete', old.id, old.english);
+    INSERT INTO words_japanese_fts (words_japanese_fts, rowid, japanese) VALUES ('delete', old.id, old.japanese);
+END;
+
+CREATE TRIGGER words_fts_update AFTER UPDATE OF japanese, romaji, english ON words BEGIN
+    INSERT INTO words_romaji_fts (words_romaji_fts, rowid, romaji) VALUES ('delete', old.id, old.romaji);
+    INSERT INTO words_english_fts (words_english_fts, rowid, english) VALUES ('delete', old.id, old.english);
+    INSERT INTO words_japanese_fts (words_japanese_fts, rowid, japanese) VALUES ('delete', old.id, old.japanese);
+    INSERT INTO words_romaji_fts (rowid, romaji) VALUES (new.id, new.romaji);
+    INSERT INTO words_english_fts (rowid, english) VALUES (new.id, new.english);
+    INSERT INTO words_japanese_fts (rowid, japanese) VALUES (new.id, new.japanese);
+END;
+
+-- Index the words that already exist
+INSERT INTO words_romaji_fts (words_romaji_fts) VALUES ('rebuild');
+INSERT INTO words_english_fts (words_english_fts) VALUES ('rebuild');
+INSERT INTO words_japanese_fts (words_japanese_fts) VALUES ('rebuild');
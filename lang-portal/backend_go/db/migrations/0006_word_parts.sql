@@ -0,0 +1,3 @@
+-- JSON array of the characters making up a word with their readings, e.g.
+-- [{"kanji": "食", "on": ["ショク"], "kun": ["た.べる"], "romaji": ["ta"]}, ...]
+ALTER TABLE words ADD COLUMN parts TEXT;
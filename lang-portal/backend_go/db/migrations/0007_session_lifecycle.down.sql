@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_study_sessions_status;
+ALTER TABLE study_sessions DROP COLUMN last_activity_at;
+ALTER TABLE study_sessions DROP COLUMN ended_at;
+ALTER TABLE study_sessions DROP COLUMN status;
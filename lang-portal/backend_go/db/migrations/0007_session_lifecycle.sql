@@ -0,0 +1,17 @@
+-- A session stays active until the learner ends it (completed) or the idle
+-- sweeper closes it (abandoned). Sessions from before this migration are
+-- treated as completed at their last review.
+ALTER TABLE study_sessions ADD COLUMN status TEXT NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'completed', 'abandoned'));
+ALTER TABLE study_sessions ADD COLUMN ended_at DATETIME;
+ALTER TABLE study_sessions ADD COLUMN last_activity_at DATETIME;
+
+UPDATE study_sessions SET
+    status = 'completed',
+    last_activity_at = COALESCE(
+        (SELECT MAX(created_at) FROM word_review_items WHERE study_session_id = study_sessions.id),
+        created_at
+    );
+UPDATE study_sessions SET ended_at = last_activity_at;
+
+CREATE INDEX IF NOT EXISTS idx_study_sessions_status ON study_sessions(status, last_activity_at);
@@ -0,0 +1,12 @@
+-- Learner preferences, kept in a single row. Daily goals of 0 are unset;
+-- without any goal a day counts towards the streak after one review.
+CREATE TABLE IF NOT EXISTS settings (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    daily_goal_reviews INTEGER NOT NULL DEFAULT 0 CHECK (daily_goal_reviews >= 0),
+    daily_goal_minutes INTEGER NOT NULL DEFAULT 0 CHECK (daily_goal_minutes >= 0),
+    streak_freezes INTEGER NOT NULL DEFAULT 0 CHECK (streak_freezes >= 0),
+    updated_at DATETIME
+);
+
+INSERT OR IGNORE INTO settings (id) VALUES (1);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_study_sessions_started_at;
+DROP INDEX IF EXISTS idx_word_review_items_session;
+DROP INDEX IF EXISTS idx_word_review_items_word_reviewed_at;
+DROP INDEX IF EXISTS idx_word_review_items_reviewed_at;
@@ -0,0 +1,7 @@
+-- Analytics filter reviews and sessions by time. Timestamps are stored in
+-- more than one text format, so they are indexed by unixepoch() and queries
+-- must use the same expression to hit these indexes.
+CREATE INDEX IF NOT EXISTS idx_word_review_items_reviewed_at ON word_review_items(unixepoch(created_at));
+CREATE INDEX IF NOT EXISTS idx_word_review_items_word_reviewed_at ON word_review_items(word_id, unixepoch(created_at));
+CREATE INDEX IF NOT EXISTS idx_word_review_items_session ON word_review_items(study_session_id);
+CREATE INDEX IF NOT EXISTS idx_study_sessions_started_at ON study_sessions(unixepoch(created_at));
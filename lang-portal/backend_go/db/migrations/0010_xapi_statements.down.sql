@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_word_review_items_xapi_statement;
+ALTER TABLE word_review_items DROP COLUMN xapi_statement_id;
+DROP TABLE IF EXISTS xapi_statements;
@@ -0,0 +1,22 @@
+-- xAPI statements received by the learning record store, kept as sent
+-- (plus the id, stored and timestamp the LRS fills in). Voided statements
+-- stay in the table but are only returned when asked for by id.
+CREATE TABLE IF NOT EXISTS xapi_statements (
+    id TEXT PRIMARY KEY,
+    verb_id TEXT NOT NULL,
+    object_id TEXT,
+    actor_key TEXT,
+    registration TEXT,
+    statement TEXT NOT NULL,
+    stored DATETIME NOT NULL,
+    voided BOOLEAN NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_xapi_statements_verb ON xapi_statements(verb_id);
+CREATE INDEX IF NOT EXISTS idx_xapi_statements_object ON xapi_statements(object_id);
+
+-- Reviews recorded from an "answered" statement point back at it, so that
+-- voiding the statement can remove the review
+ALTER TABLE word_review_items ADD COLUMN xapi_statement_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_word_review_items_xapi_statement ON word_review_items(xapi_statement_id)
+    WHERE xapi_statement_id IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE study_activities DROP COLUMN updated_at;
+ALTER TABLE study_activities DROP COLUMN enabled;
+ALTER TABLE study_activities DROP COLUMN prompt_types;
+ALTER TABLE study_activities DROP COLUMN launch_url;
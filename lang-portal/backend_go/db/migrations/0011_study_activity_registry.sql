@@ -0,0 +1,8 @@
+-- Study activities become a registry of the apps the portal can launch.
+-- launch_url is a template filled in with the session being launched;
+-- prompt_types lists the review directions the app asks words in, as JSON.
+-- Disabled activities are kept for their history but cannot be launched.
+ALTER TABLE study_activities ADD COLUMN launch_url TEXT;
+ALTER TABLE study_activities ADD COLUMN prompt_types TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE study_activities ADD COLUMN enabled BOOLEAN NOT NULL DEFAULT 1;
+ALTER TABLE study_activities ADD COLUMN updated_at DATETIME;
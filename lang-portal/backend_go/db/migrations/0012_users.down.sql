@@ -0,0 +1,49 @@
+-- Only the local learner's schedules and settings survive going back to a
+-- single user
+CREATE TABLE settings_single (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    daily_goal_reviews INTEGER NOT NULL DEFAULT 0 CHECK (daily_goal_reviews >= 0),
+    daily_goal_minutes INTEGER NOT NULL DEFAULT 0 CHECK (daily_goal_minutes >= 0),
+    streak_freezes INTEGER NOT NULL DEFAULT 0 CHECK (streak_freezes >= 0),
+    updated_at DATETIME
+);
+INSERT INTO settings_single (id, timezone, daily_goal_reviews, daily_goal_minutes, streak_freezes, updated_at)
+SELECT user_id, timezone, daily_goal_reviews, daily_goal_minutes, streak_freezes, updated_at
+FROM settings WHERE user_id = 1;
+INSERT OR IGNORE INTO settings_single (id) VALUES (1);
+DROP TABLE settings;
+ALTER TABLE settings_single RENAME TO settings;
+
+CREATE TABLE word_schedules_single (
+    word_id INTEGER PRIMARY KEY,
+    ease_factor REAL NOT NULL DEFAULT 2.5,
+    interval_days INTEGER NOT NULL DEFAULT 0,
+    repetitions INTEGER NOT NULL DEFAULT 0,
+    lapses INTEGER NOT NULL DEFAULT 0,
+    due_at DATETIME NOT NULL,
+    last_reviewed_at DATETIME,
+    FOREIGN KEY (word_id) REFERENCES words(id)
+);
+INSERT INTO word_schedules_single
+    (word_id, ease_factor, interval_days, repetitions, lapses, due_at, last_reviewed_at)
+SELECT word_id, ease_factor, interval_days, repetitions, lapses, due_at, last_reviewed_at
+FROM word_schedules WHERE user_id = 1;
+DROP INDEX IF EXISTS idx_word_schedules_due_at;
+DROP TABLE word_schedules;
+ALTER TABLE word_schedules_single RENAME TO word_schedules;
+CREATE INDEX IF NOT EXISTS idx_word_schedules_due_at ON word_schedules(due_at);
+
+DROP INDEX IF EXISTS idx_xapi_statements_user;
+ALTER TABLE xapi_statements DROP COLUMN user_id;
+
+DROP INDEX IF EXISTS idx_word_review_items_user_reviewed_at;
+DROP INDEX IF EXISTS idx_word_review_items_user_word;
+ALTER TABLE word_review_items DROP COLUMN user_id;
+
+DROP INDEX IF EXISTS idx_study_sessions_user_started_at;
+ALTER TABLE study_sessions DROP COLUMN user_id;
+
+DROP INDEX IF EXISTS idx_auth_tokens_user;
+DROP TABLE IF EXISTS auth_tokens;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,81 @@
+-- Learner accounts. Study history, schedules, settings and xAPI statements
+-- belong to a learner; words, groups and study activities stay shared.
+-- User 1 is the local learner that single-user mode studies as, and that
+-- owns everything studied before this migration. An empty password hash
+-- cannot be logged in with until the first registered account claims it.
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    password_hash TEXT NOT NULL,
+    is_admin BOOLEAN NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT OR IGNORE INTO users (id, username, password_hash, is_admin) VALUES (1, 'local', '', 1);
+
+-- Sign-in tokens are kept as SHA-256 hashes, so a copy of the database
+-- cannot be used to sign in
+CREATE TABLE IF NOT EXISTS auth_tokens (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_tokens_user ON auth_tokens(user_id);
+
+ALTER TABLE study_sessions ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1;
+CREATE INDEX IF NOT EXISTS idx_study_sessions_user_started_at ON study_sessions(user_id, unixepoch(created_at));
+
+-- Reviews repeat their session's learner so per-learner stats need no join
+ALTER TABLE word_review_items ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1;
+UPDATE word_review_items SET user_id = COALESCE(
+    (SELECT user_id FROM study_sessions WHERE id = word_review_items.study_session_id),
+    1
+);
+CREATE INDEX IF NOT EXISTS idx_word_review_items_user_word ON word_review_items(user_id, word_id);
+CREATE INDEX IF NOT EXISTS idx_word_review_items_user_reviewed_at ON word_review_items(user_id, unixepoch(created_at));
+
+ALTER TABLE xapi_statements ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1;
+CREATE INDEX IF NOT EXISTS idx_xapi_statements_user ON xapi_statements(user_id);
+
+-- Every learner schedules words separately
+CREATE TABLE word_schedules_by_user (
+    user_id INTEGER NOT NULL,
+    word_id INTEGER NOT NULL,
+    ease_factor REAL NOT NULL DEFAULT 2.5,
+    interval_days INTEGER NOT NULL DEFAULT 0,
+    repetitions INTEGER NOT NULL DEFAULT 0,
+    lapses INTEGER NOT NULL DEFAULT 0,
+    due_at DATETIME NOT NULL,
+    last_reviewed_at DATETIME,
+    PRIMARY KEY (user_id, word_id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (word_id) REFERENCES words(id)
+);
+INSERT INTO word_schedules_by_user
+    (user_id, word_id, ease_factor, interval_days, repetitions, lapses, due_at, last_reviewed_at)
+SELECT 1, word_id, ease_factor, interval_days, repetitions, lapses, due_at, last_reviewed_at
+FROM word_schedules;
+DROP INDEX IF EXISTS idx_word_schedules_due_at;
+DROP TABLE word_schedules;
+ALTER TABLE word_schedules_by_user RENAME TO word_schedules;
+CREATE INDEX IF NOT EXISTS idx_word_schedules_due_at ON word_schedules(user_id, due_at);
+
+-- Every learner keeps their own settings row
+CREATE TABLE settings_by_user (
+    user_id INTEGER PRIMARY KEY,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    daily_goal_reviews INTEGER NOT NULL DEFAULT 0 CHECK (daily_goal_reviews >= 0),
+    daily_goal_minutes INTEGER NOT NULL DEFAULT 0 CHECK (daily_goal_minutes >= 0),
+    streak_freezes INTEGER NOT NULL DEFAULT 0 CHECK (streak_freezes >= 0),
+    updated_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+INSERT INTO settings_by_user
+    (user_id, timezone, daily_goal_reviews, daily_goal_minutes, streak_freezes, updated_at)
+SELECT id, timezone, daily_goal_reviews, daily_goal_minutes, streak_freezes, updated_at
+FROM settings;
+DROP TABLE settings;
+ALTER TABLE settings_by_user RENAME TO settings;
@@ -20,19 +20,9 @@ func InitDB() (*sql.DB, error) {
 		return InitTestDB()
 	}
 
-	// Get project root directory (backend_go)
-	pwd, err := os.Getwd()
+	projectRoot, err := FindProjectRoot()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get working directory: %v", err)
-	}
-	
-	// Find backend_go directory
-	projectRoot := pwd
-	for filepath.Base(projectRoot) != "backend_go" && projectRoot != "/" {
-		projectRoot = filepath.Dir(projectRoot)
-	}
-	if projectRoot == "/" {
-		return nil, fmt.Errorf("could not find backend_go directory")
+		return nil, err
 	}
 	log.Printf("Project root directory: %s", projectRoot)
 
@@ -46,7 +36,7 @@ func InitDB() (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to create database directory: %v", err)
 	}
 
-	// Check if database exists, if not, create it and initialize schema
+	// Check if database exists, if not, create it and seed it after migrating
 	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
 		log.Printf("Database does not exist, creating new database")
 		db, err := createNewDatabase(dbPath, projectRoot)
@@ -62,10 +52,33 @@ func InitDB() (*sql.DB, error) {
 		return nil, fmt.Errorf("error opening database: %v", err)
 	}
 
+	// Bring the schema up to date
+	if err := Migrate(db, MigrationsDir(projectRoot)); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %v", err)
+	}
+
 	DB = db
 	return db, nil
 }
 
+// FindProjectRoot walks up from the working directory to the backend_go directory
+func FindProjectRoot() (string, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %v", err)
+	}
+
+	projectRoot := pwd
+	for filepath.Base(projectRoot) != "backend_go" && projectRoot != "/" {
+		projectRoot = filepath.Dir(projectRoot)
+	}
+	if projectRoot == "/" {
+		return "", fmt.Errorf("could not find backend_go directory")
+	}
+	return projectRoot, nil
+}
+
 func getDatabasePath() string {
 	env := os.Getenv("APP_ENV")
 	if env == "" {
@@ -90,24 +103,16 @@ func createNewDatabase(dbPath string, projectRoot string) (*sql.DB, error) {
 		return nil, err
 	}
 
-	// Read and execute schema
-	schemaPath := filepath.Join(projectRoot, "db", "migrations", "0001_init.sql")
-	log.Printf("Reading schema from: %s", schemaPath)
-	schemaSQL, err := os.ReadFile(schemaPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read schema file: %v", err)
-	}
-
-	_, err = db.Exec(string(schemaSQL))
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute schema: %v", err)
+	// Apply all migrations to build the schema
+	if err := Migrate(db, MigrationsDir(projectRoot)); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %v", err)
 	}
-	log.Printf("Schema executed successfully")
+	log.Printf("Migrations applied successfully")
 
 	// If it's test environment, insert test data
 	if os.Getenv("APP_ENV") == "test" {
 		log.Printf("Inserting test data...")
-		if err := insertTestData(db); err != nil {
+		if err := InsertTestData(db); err != nil {
 			return nil, fmt.Errorf("failed to insert test data: %v", err)
 		}
 		log.Printf("Test data inserted successfully")
@@ -122,27 +127,3 @@ func createNewDatabase(dbPath string, projectRoot string) (*sql.DB, error) {
 	DB = db
 	return db, nil
 }
-
-func insertTestData(db *sql.DB) error {
-	testData := []string{
-		// Groups
-		`INSERT INTO groups (id, name) VALUES (1, 'Basic Greetings');`,
-		`INSERT INTO groups (id, name) VALUES (2, 'Numbers');`,
-		
-		// Words
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (1, 'こんにちは', 'konnichiwa', 'hello');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (2, 'さようなら', 'sayounara', 'goodbye');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (3, 'おはよう', 'ohayou', 'good morning');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (4, '一', 'ichi', 'one');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (5, '二', 'ni', 'two');`,
-	}
-
-	for _, query := range testData {
-		log.Printf("Executing query: %s", query)
-		if _, err := db.Exec(query); err != nil {
-			return fmt.Errorf("error executing test data query: %v", err)
-		}
-	}
-
-	return nil
-} 
\ No newline at end of file
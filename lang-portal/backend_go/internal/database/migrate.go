@@ -0,0 +1,266 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration files are named NNNN_description.sql, with an optional
+// NNNN_description.down.sql next to them to undo the change
+var migrationFilePattern = regexp.MustCompile(`^(\d{4})_(.+?)(\.down)?\.sql$`)
+
+type Migration struct {
+	Version  int
+	Name     string
+	UpPath   string
+	DownPath string
+	Checksum string
+}
+
+type AppliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// MigrationsDir returns the migrations folder for the given project root
+func MigrationsDir(projectRoot string) string {
+	return filepath.Join(projectRoot, "db", "migrations")
+}
+
+// LoadMigrations reads all migration files in dir, ordered by version
+func LoadMigrations(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory: %v", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version}
+			byVersion[version] = m
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		if match[3] != "" {
+			m.DownPath = path
+			continue
+		}
+		if m.UpPath != "" {
+			return nil, fmt.Errorf("duplicate migration version %04d: %s and %s",
+				version, filepath.Base(m.UpPath), entry.Name())
+		}
+		m.Name = match[2]
+		m.UpPath = path
+
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", entry.Name(), err)
+		}
+		sum := sha256.Sum256(contents)
+		m.Checksum = hex.EncodeToString(sum[:])
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.UpPath == "" {
+			return nil, fmt.Errorf("down migration %s has no matching up migration", filepath.Base(m.DownPath))
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	return err
+}
+
+// AppliedMigrations returns the migrations recorded in schema_migrations,
+// ordered by version
+func AppliedMigrations(db *sql.DB) ([]AppliedMigration, error) {
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT version, name, checksum, applied_at
+		FROM schema_migrations
+		ORDER BY version
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var m AppliedMigration
+		if err := rows.Scan(&m.Version, &m.Name, &m.Checksum, &m.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, m)
+	}
+	return applied, rows.Err()
+}
+
+// Migrate applies every pending migration in dir, each inside its own
+// transaction. It refuses to run if an already applied migration file has
+// been modified or removed since it was applied.
+func Migrate(db *sql.DB, dir string) error {
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		return err
+	}
+
+	applied, err := AppliedMigrations(db)
+	if err != nil {
+		return fmt.Errorf("failed to read applied migrations: %v", err)
+	}
+
+	byVersion := map[int]Migration{}
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	done := map[int]bool{}
+	for _, a := range applied {
+		m, ok := byVersion[a.Version]
+		if !ok {
+			return fmt.Errorf("applied migration %04d_%s is missing from %s", a.Version, a.Name, dir)
+		}
+		if m.Checksum != a.Checksum {
+			return fmt.Errorf("checksum mismatch for applied migration %s: file has been modified",
+				filepath.Base(m.UpPath))
+		}
+		done[a.Version] = true
+	}
+
+	for _, m := range migrations {
+		if done[m.Version] {
+			continue
+		}
+		log.Printf("Applying migration %s", filepath.Base(m.UpPath))
+		if err := applyMigration(db, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyMigration(db *sql.DB, m Migration) error {
+	contents, err := os.ReadFile(m.UpPath)
+	if err != nil {
+		return fmt.Errorf("failed to read migration %s: %v", filepath.Base(m.UpPath), err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if strings.TrimSpace(string(contents)) != "" {
+		if _, err := tx.Exec(string(contents)); err != nil {
+			if strings.Contains(err.Error(), "no such module: fts5") {
+				return fmt.Errorf("failed to apply migration %s: %v (build with -tags sqlite_fts5)",
+					filepath.Base(m.UpPath), err)
+			}
+			return fmt.Errorf("failed to apply migration %s: %v", filepath.Base(m.UpPath), err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO schema_migrations (version, name, checksum, applied_at)
+		VALUES (?, ?, ?, ?)
+	`, m.Version, m.Name, m.Checksum, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record migration %s: %v", filepath.Base(m.UpPath), err)
+	}
+
+	return tx.Commit()
+}
+
+// MigrateDown rolls back the given number of most recently applied
+// migrations using their .down.sql files
+func MigrateDown(db *sql.DB, dir string, steps int) error {
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		return err
+	}
+
+	applied, err := AppliedMigrations(db)
+	if err != nil {
+		return fmt.Errorf("failed to read applied migrations: %v", err)
+	}
+
+	byVersion := map[int]Migration{}
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	for i := len(applied) - 1; i >= 0 && steps > 0; i, steps = i-1, steps-1 {
+		a := applied[i]
+		m, ok := byVersion[a.Version]
+		if !ok || m.DownPath == "" {
+			return fmt.Errorf("no down migration found for %04d_%s", a.Version, a.Name)
+		}
+
+		log.Printf("Rolling back migration %s", filepath.Base(m.DownPath))
+		contents, err := os.ReadFile(m.DownPath)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %v", filepath.Base(m.DownPath), err)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(string(contents)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to roll back migration %s: %v", filepath.Base(m.DownPath), err)
+		}
+		if _, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", a.Version); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
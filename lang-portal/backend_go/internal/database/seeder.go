@@ -2,34 +2,358 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
 )
 
+const (
+	SeedTypeWords           = "words"
+	SeedTypeStudyActivities = "study_activities"
+)
+
+// wordColumns are the words table columns a seed mapping may target, and
+// whether every record must provide them
+var wordColumns = map[string]bool{
+	"japanese": true,
+	"romaji":   true,
+	"english":  true,
+	"parts":    false,
+}
+
+// SeedManifestEntry describes one seed file in db/seeds/manifest.json.
+//
+// Word seeds are linked to Group (created if missing). Mapping renames
+// fields of the seed records to words columns, e.g. {"kanji": "japanese"};
+// fields that are not mapped are used as-is when they match a column name.
+type SeedManifestEntry struct {
+	File    string            `json:"file"`
+	Type    string            `json:"type"`
+	Group   string            `json:"group"`
+	Mapping map[string]string `json:"mapping"`
+}
+
+// SeedResult summarises what an import changed
+type SeedResult struct {
+	WordsCreated      int
+	WordsUpdated      int
+	LinksCreated      int
+	GroupsCreated     int
+	ActivitiesCreated int
+	ActivitiesUpdated int
+}
+
+// SeedsDir returns the seeds folder for the given project root
+func SeedsDir(projectRoot string) string {
+	return filepath.Join(projectRoot, "db", "seeds")
+}
+
+// LoadSeedManifest reads and validates manifest.json from the seeds directory
+func LoadSeedManifest(dir string) ([]SeedManifestEntry, error) {
+	contents, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed manifest: %v", err)
+	}
+
+	var entries []SeedManifestEntry
+	if err := json.Unmarshal(contents, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse seed manifest: %v", err)
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		if entry.File == "" {
+			return nil, fmt.Errorf("seed manifest entry %d has no file", i)
+		}
+		if entry.Type == "" {
+			entry.Type = SeedTypeWords
+		}
+		switch entry.Type {
+		case SeedTypeWords:
+			if entry.Group == "" {
+				return nil, fmt.Errorf("seed %s has no target group", entry.File)
+			}
+			for source, target := range entry.Mapping {
+				if _, ok := wordColumns[target]; !ok {
+					return nil, fmt.Errorf("seed %s maps %q to unknown column %q", entry.File, source, target)
+				}
+			}
+		case SeedTypeStudyActivities:
+		default:
+			return nil, fmt.Errorf("seed %s has unknown type %q", entry.File, entry.Type)
+		}
+	}
+
+	return entries, nil
+}
+
+// ImportSeeds imports every seed file listed in the manifest inside a single
+// transaction. Words are matched on japanese and romaji, so running the
+// import again updates existing rows instead of duplicating them.
+func ImportSeeds(db *sql.DB, dir string) (*SeedResult, error) {
+	entries, err := LoadSeedManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &SeedResult{}
+	for _, entry := range entries {
+		log.Printf("Importing seed %s", entry.File)
+
+		var records []map[string]interface{}
+		contents, err := os.ReadFile(filepath.Join(dir, entry.File))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read seed %s: %v", entry.File, err)
+		}
+		if err := json.Unmarshal(contents, &records); err != nil {
+			return nil, fmt.Errorf("failed to parse seed %s: %v", entry.File, err)
+		}
+
+		switch entry.Type {
+		case SeedTypeWords:
+			err = importWords(tx, entry, records, result)
+		case SeedTypeStudyActivities:
+			err = importStudyActivities(tx, entry, records, result)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// mapRecord applies the manifest mapping to a seed record and returns the
+// word columns it provides. Parts are returned re-encoded as JSON.
+func mapRecord(entry SeedManifestEntry, record map[string]interface{}) (map[string]string, error) {
+	columns := map[string]string{}
+	for field, value := range record {
+		column, ok := entry.Mapping[field]
+		if !ok {
+			column = field
+		}
+		if _, ok := wordColumns[column]; !ok {
+			continue
+		}
+		if column == "parts" {
+			parts, err := encodeParts(value)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %v", field, err)
+			}
+			columns[column] = parts
+			continue
+		}
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %q must be a string", field)
+		}
+		columns[column] = text
+	}
+
+	for column, required := range wordColumns {
+		if required && columns[column] == "" {
+			return nil, fmt.Errorf("missing value for %s", column)
+		}
+	}
+	return columns, nil
+}
+
+// encodeParts checks that a seed's parts have the shape of models.WordPart
+// and encodes them for the parts column
+func encodeParts(value interface{}) (string, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	var parts []models.WordPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("parts must be a list of {kanji, on, kun, romaji}")
+	}
+	if len(parts) == 0 {
+		return "", nil
+	}
+	for _, part := range parts {
+		if part.Kanji == "" || len(part.Romaji) == 0 {
+			return "", fmt.Errorf("every part needs kanji and romaji")
+		}
+	}
+	encoded, err := json.Marshal(parts)
+	return string(encoded), err
+}
+
+func importWords(tx *sql.Tx, entry SeedManifestEntry, records []map[string]interface{}, result *SeedResult) error {
+	groupID, created, err := findOrCreateGroup(tx, entry.Group)
+	if err != nil {
+		return fmt.Errorf("seed %s: %v", entry.File, err)
+	}
+	if created {
+		result.GroupsCreated++
+	}
+
+	for i, record := range records {
+		columns, err := mapRecord(entry, record)
+		if err != nil {
+			return fmt.Errorf("seed %s record %d: %v", entry.File, i, err)
+		}
+
+		var wordID int64
+		err = tx.QueryRow(
+			"SELECT id FROM words WHERE japanese = ? AND romaji = ?",
+			columns["japanese"], columns["romaji"],
+		).Scan(&wordID)
+		switch {
+		case err == sql.ErrNoRows:
+			res, err := tx.Exec(
+				"INSERT INTO words (japanese, romaji, english, parts) VALUES (?, ?, ?, ?)",
+				columns["japanese"], columns["romaji"], columns["english"], nullableText(columns["parts"]),
+			)
+			if err != nil {
+				return fmt.Errorf("seed %s record %d: %v", entry.File, i, err)
+			}
+			if wordID, err = res.LastInsertId(); err != nil {
+				return err
+			}
+			result.WordsCreated++
+		case err != nil:
+			return fmt.Errorf("seed %s record %d: %v", entry.File, i, err)
+		default:
+			// Parts are only replaced when the seed provides them
+			parts := nullableText(columns["parts"])
+			res, err := tx.Exec(`
+				UPDATE words SET english = ?, parts = COALESCE(?, parts)
+				WHERE id = ? AND (english != ? OR (? IS NOT NULL AND parts IS NOT ?))
+			`, columns["english"], parts, wordID, columns["english"], parts, parts)
+			if err != nil {
+				return fmt.Errorf("seed %s record %d: %v", entry.File, i, err)
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				result.WordsUpdated++
+			}
+		}
+
+		res, err := tx.Exec(`
+			INSERT INTO words_groups (word_id, group_id)
+			SELECT ?, ?
+			WHERE NOT EXISTS (
+				SELECT 1 FROM words_groups WHERE word_id = ? AND group_id = ?
+			)
+		`, wordID, groupID, wordID, groupID)
+		if err != nil {
+			return fmt.Errorf("seed %s record %d: %v", entry.File, i, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			result.LinksCreated++
+		}
+	}
+
+	return nil
+}
+
+func nullableText(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func findOrCreateGroup(tx *sql.Tx, name string) (int64, bool, error) {
+	var id int64
+	err := tx.QueryRow("SELECT id FROM groups WHERE name = ?", name).Scan(&id)
+	if err == nil {
+		return id, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, err
+	}
+
+	res, err := tx.Exec("INSERT INTO groups (name) VALUES (?)", name)
+	if err != nil {
+		return 0, false, err
+	}
+	id, err = res.LastInsertId()
+	return id, true, err
+}
+
+func importStudyActivities(tx *sql.Tx, entry SeedManifestEntry, records []map[string]interface{}, result *SeedResult) error {
+	for i, record := range records {
+		name, _ := record["name"].(string)
+		if name == "" {
+			return fmt.Errorf("seed %s record %d: missing value for name", entry.File, i)
+		}
+		thumbnailURL, _ := record["thumbnail_url"].(string)
+		description, _ := record["description"].(string)
+		launchURL, _ := record["launch_url"].(string)
+
+		promptTypes := []string{}
+		if values, ok := record["prompt_types"].([]interface{}); ok {
+			for _, value := range values {
+				promptType, _ := value.(string)
+				switch promptType {
+				case models.DirectionJapaneseToEnglish, models.DirectionEnglishToJapanese, models.DirectionAudioToJapanese:
+					promptTypes = append(promptTypes, promptType)
+				default:
+					return fmt.Errorf("seed %s record %d: unknown prompt type %v", entry.File, i, value)
+				}
+			}
+		}
+		encodedPromptTypes, err := json.Marshal(promptTypes)
+		if err != nil {
+			return err
+		}
+
+		// Only seeds that say so change whether an activity is enabled, so
+		// re-seeding keeps activities switched off through the API disabled
+		enabled, hasEnabled := record["enabled"].(bool)
+
+		res, err := tx.Exec(`
+			UPDATE study_activities SET thumbnail_url = ?, description = ?, launch_url = ?, prompt_types = ?,
+				enabled = CASE WHEN ? THEN ? ELSE enabled END
+			WHERE name = ?
+		`, thumbnailURL, description, nullableText(launchURL), string(encodedPromptTypes), hasEnabled, enabled, name)
+		if err != nil {
+			return fmt.Errorf("seed %s record %d: %v", entry.File, i, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			result.ActivitiesUpdated++
+			continue
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO study_activities (name, thumbnail_url, description, launch_url, prompt_types, enabled)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, name, thumbnailURL, description, nullableText(launchURL), string(encodedPromptTypes), !hasEnabled || enabled)
+		if err != nil {
+			return fmt.Errorf("seed %s record %d: %v", entry.File, i, err)
+		}
+		result.ActivitiesCreated++
+	}
+
+	return nil
+}
+
 func SeedProductionData(db *sql.DB) error {
 	log.Printf("Seeding production database...")
 
-	productionData := []string{
-		// Groups
-		`INSERT INTO groups (id, name) VALUES (1, 'Basic Greetings');`,
-		`INSERT INTO groups (id, name) VALUES (2, 'Numbers');`,
-		
-		// Words
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (1, 'こんにちは', 'konnichiwa', 'hello');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (2, 'さようなら', 'sayounara', 'goodbye');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (3, 'おはよう', 'ohayou', 'good morning');`,
-		
-		// Study Activities
-		`INSERT INTO study_activities (id, name, thumbnail_url, description) 
-		 VALUES (1, 'Flashcards', '/images/flashcards.png', 'Practice words using flashcards');`,
+	projectRoot, err := FindProjectRoot()
+	if err != nil {
+		return err
 	}
 
-	for _, query := range productionData {
-		if _, err := db.Exec(query); err != nil {
-			return fmt.Errorf("error seeding production data: %v", err)
-		}
+	result, err := ImportSeeds(db, SeedsDir(projectRoot))
+	if err != nil {
+		return fmt.Errorf("error seeding production data: %v", err)
 	}
 
-	log.Printf("Production database seeded successfully")
+	log.Printf("Production database seeded successfully: %d words created, %d updated",
+		result.WordsCreated, result.WordsUpdated)
 	return nil
-} 
\ No newline at end of file
+}
@@ -11,18 +11,9 @@ import (
 func InitTestDB() (*sql.DB, error) {
 	log.Printf("Initializing test database...")
 	
-	// Get project root directory (backend_go)
-	pwd, err := os.Getwd()
+	projectRoot, err := FindProjectRoot()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get working directory: %v", err)
-	}
-	
-	projectRoot := pwd
-	for filepath.Base(projectRoot) != "backend_go" && projectRoot != "/" {
-		projectRoot = filepath.Dir(projectRoot)
-	}
-	if projectRoot == "/" {
-		return nil, fmt.Errorf("could not find backend_go directory")
+		return nil, err
 	}
 
 	dbPath := filepath.Join(projectRoot, "db", "data", "words.test.db")
@@ -43,17 +34,9 @@ func InitTestDB() (*sql.DB, error) {
 		return nil, err
 	}
 
-	// Read and execute schema
-	schemaPath := filepath.Join(projectRoot, "db", "migrations", "0001_init.sql")
-	log.Printf("Reading schema from: %s", schemaPath)
-	schemaSQL, err := os.ReadFile(schemaPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read schema file: %v", err)
-	}
-
-	_, err = db.Exec(string(schemaSQL))
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute schema: %v", err)
+	// Apply all migrations to build the schema
+	if err := Migrate(db, MigrationsDir(projectRoot)); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %v", err)
 	}
 
 	// Insert test data
@@ -68,29 +51,14 @@ func InitTestDB() (*sql.DB, error) {
 
 // Make InsertTestData public so it can be called after reset
 func InsertTestData(db *sql.DB) error {
-	testData := []string{
-		// Groups
-		`INSERT INTO groups (id, name) VALUES (1, 'Basic Greetings');`,
-		`INSERT INTO groups (id, name) VALUES (2, 'Numbers');`,
-		
-		// Words
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (1, 'こんにちは', 'konnichiwa', 'hello');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (2, 'さようなら', 'sayounara', 'goodbye');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (3, 'おはよう', 'ohayou', 'good morning');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (4, '一', 'ichi', 'one');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (5, '二', 'ni', 'two');`,
-
-		// Study Activities
-		`INSERT INTO study_activities (id, name, thumbnail_url, description) 
-		 VALUES (1, 'Flashcards', '/images/flashcards.png', 'Practice words using flashcards');`,
+	projectRoot, err := FindProjectRoot()
+	if err != nil {
+		return err
 	}
 
-	for _, query := range testData {
-		log.Printf("Executing: %s", query)
-		if _, err := db.Exec(query); err != nil {
-			return fmt.Errorf("error executing test data query: %v", err)
-		}
+	if _, err := ImportSeeds(db, SeedsDir(projectRoot)); err != nil {
+		return fmt.Errorf("error importing test data: %v", err)
 	}
 
 	return nil
-} 
\ No newline at end of file
+}
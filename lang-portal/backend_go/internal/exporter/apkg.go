@@ -0,0 +1,274 @@
+package exporter
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+)
+
+// ankiNoteTypeID is fixed so that importing a newer export into Anki updates
+// the note type and notes from an earlier one instead of duplicating them
+const ankiNoteTypeID int64 = 1735689600000
+
+// ankiSchema is the legacy (schema 11) collection layout, which every Anki
+// version can import
+const ankiSchema = `
+CREATE TABLE col (
+	id integer primary key, crt integer not null, mod integer not null, scm integer not null,
+	ver integer not null, dty integer not null, usn integer not null, ls integer not null,
+	conf text not null, models text not null, decks text not null, dconf text not null, tags text not null
+);
+CREATE TABLE notes (
+	id integer primary key, guid text not null, mid integer not null, mod integer not null,
+	usn integer not null, tags text not null, flds text not null, sfld integer not null,
+	csum integer not null, flags integer not null, data text not null
+);
+CREATE TABLE cards (
+	id integer primary key, nid integer not null, did integer not null, ord integer not null,
+	mod integer not null, usn integer not null, type integer not null, queue integer not null,
+	due integer not null, ivl integer not null, factor integer not null, reps integer not null,
+	lapses integer not null, left integer not null, odue integer not null, odid integer not null,
+	flags integer not null, data text not null
+);
+CREATE TABLE revlog (
+	id integer primary key, cid integer not null, usn integer not null, ease integer not null,
+	ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null,
+	type integer not null
+);
+CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null);
+CREATE INDEX ix_notes_usn on notes (usn);
+CREATE INDEX ix_cards_usn on cards (usn);
+CREATE INDEX ix_revlog_usn on revlog (usn);
+CREATE INDEX ix_cards_nid on cards (nid);
+CREATE INDEX ix_cards_sched on cards (did, queue, due);
+CREATE INDEX ix_revlog_cid on revlog (cid);
+CREATE INDEX ix_notes_csum on notes (csum);
+`
+
+// Anki card types and queues used by the export
+const (
+	ankiCardNew    = 0
+	ankiCardReview = 2
+)
+
+// ankiDeck describes a deck in the collection's decks JSON
+func ankiDeck(id int64, name string, mod int64) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "name": name, "mod": mod, "usn": -1, "desc": "",
+		"dyn": 0, "conf": 1, "collapsed": false, "browserCollapsed": false,
+		"extendNew": 0, "extendRev": 0,
+		"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+	}
+}
+
+// ankiNoteType is a note type with Japanese, Romaji and English fields and a
+// single card showing the Japanese
+func ankiNoteType(deckID, mod int64) map[string]interface{} {
+	fields := make([]map[string]interface{}, 0, 3)
+	for i, name := range []string{"Japanese", "Romaji", "English"} {
+		fields = append(fields, map[string]interface{}{
+			"name": name, "ord": i, "sticky": false, "rtl": false,
+			"font": "Arial", "size": 20, "media": []string{},
+		})
+	}
+	return map[string]interface{}{
+		"id": ankiNoteTypeID, "name": "Lang Portal Word", "type": 0, "mod": mod, "usn": -1,
+		"sortf": 0, "did": deckID, "flds": fields,
+		"tmpls": []map[string]interface{}{{
+			"name": "Recognition", "ord": 0, "did": nil, "bqfmt": "", "bafmt": "",
+			"qfmt": "<div class=japanese>{{Japanese}}</div>",
+			"afmt": "{{FrontSide}}\n\n<hr id=answer>\n\n{{Romaji}}<br>\n{{English}}",
+		}},
+		"css":       ".card { font-family: arial; font-size: 20px; text-align: center; }\n.japanese { font-size: 40px; }",
+		"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+		"latexPost": "\\end{document}",
+		"req":       []interface{}{[]interface{}{0, "any", []int{0}}},
+		"tags":      []string{},
+		"vers":      []string{},
+	}
+}
+
+// ankiDeckOptions are Anki's default deck options
+var ankiDeckOptions = map[string]interface{}{
+	"1": map[string]interface{}{
+		"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60,
+		"autoplay": true, "timer": 0, "replayq": true, "dyn": false,
+		"new": map[string]interface{}{
+			"bury": false, "delays": []float64{1, 10}, "initialFactor": 2500,
+			"ints": []int{1, 4, 0}, "order": 1, "perDay": 20,
+		},
+		"rev": map[string]interface{}{
+			"bury": false, "ease4": 1.3, "ivlFct": 1, "maxIvl": 36500, "perDay": 200, "hardFactor": 1.2,
+		},
+		"lapse": map[string]interface{}{
+			"delays": []float64{10}, "leechAction": 1, "leechFails": 8, "minInt": 1, "mult": 0,
+		},
+	},
+}
+
+// ankiChecksum is the first 8 hex digits of the SHA-1 of a note's sort
+// field, as an integer, which Anki uses to spot duplicates
+func ankiChecksum(field string) int64 {
+	sum := sha1.Sum([]byte(field))
+	checksum, _ := strconv.ParseInt(hex.EncodeToString(sum[:4]), 16, 64)
+	return checksum
+}
+
+// ankiTags turns group names into space separated Anki tags
+func ankiTags(groups []string) string {
+	if len(groups) == 0 {
+		return ""
+	}
+	tags := make([]string, len(groups))
+	for i, group := range groups {
+		tags[i] = strings.Join(strings.Fields(group), "_")
+	}
+	return " " + strings.Join(tags, " ") + " "
+}
+
+// daysSince counts whole UTC days from start to t
+func daysSince(start, t time.Time) int {
+	return int(t.UTC().Truncate(24*time.Hour).Sub(start).Hours() / 24)
+}
+
+// WriteApkg writes the words as an Anki package with one note per word,
+// tagged with its groups. Words with an SM-2 schedule become review cards
+// keeping their interval, ease, due date and lapses; the rest are new cards.
+func WriteApkg(w io.Writer, export *models.WordExport) error {
+	tmp, err := os.CreateTemp("", "export-*.anki2")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	db, err := sql.Open("sqlite3", tmp.Name())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := fillCollection(db, export); err != nil {
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	collection, err := os.Open(tmp.Name())
+	if err != nil {
+		return err
+	}
+	defer collection.Close()
+
+	archive := zip.NewWriter(w)
+	file, err := archive.Create("collection.anki2")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, collection); err != nil {
+		return err
+	}
+	// The package carries no media
+	media, err := archive.Create("media")
+	if err != nil {
+		return err
+	}
+	if _, err := media.Write([]byte("{}")); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// fillCollection creates the collection schema and writes the deck, note
+// type, notes and cards into it
+func fillCollection(db *sql.DB, export *models.WordExport) error {
+	if _, err := db.Exec(ankiSchema); err != nil {
+		return err
+	}
+
+	now := export.ExportedAt
+	mod := now.Unix()
+	// Review cards are due a number of days after the collection was
+	// created, so date the collection before the earliest due date
+	created := now.UTC().Truncate(24 * time.Hour)
+	for _, word := range export.Words {
+		if word.DueAt != nil && word.DueAt.Before(created) {
+			created = word.DueAt.UTC().Truncate(24 * time.Hour)
+		}
+	}
+
+	deckName := "Lang Portal"
+	if export.GroupName != "" {
+		deckName += "::" + export.GroupName
+	}
+	deckID := now.UnixMilli()
+
+	conf, _ := json.Marshal(map[string]interface{}{
+		"nextPos": len(export.Words) + 1, "estTimes": true, "activeDecks": []int64{deckID},
+		"sortType": "noteFld", "timeLim": 0, "sortBackwards": false, "addToCur": true,
+		"curDeck": deckID, "newBust": true, "dueCounts": true, "curModel": ankiNoteTypeID,
+		"collapseTime": 1200,
+	})
+	noteTypes, _ := json.Marshal(map[string]interface{}{
+		strconv.FormatInt(ankiNoteTypeID, 10): ankiNoteType(deckID, mod),
+	})
+	decks, _ := json.Marshal(map[string]interface{}{
+		"1":                            ankiDeck(1, "Default", mod),
+		strconv.FormatInt(deckID, 10): ankiDeck(deckID, deckName, mod),
+	})
+	deckOptions, _ := json.Marshal(ankiDeckOptions)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO col (id, crt, mod, scm, ver, dty, usn, ls, conf, models, decks, dconf, tags)
+		VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')
+	`, created.Unix(), now.UnixMilli(), now.UnixMilli(), string(conf), string(noteTypes), string(decks), string(deckOptions))
+	if err != nil {
+		return err
+	}
+
+	for i, word := range export.Words {
+		// Note and card IDs are creation times in milliseconds
+		id := now.UnixMilli() + int64(i)
+		fields := []string{html.EscapeString(word.Japanese), html.EscapeString(word.Romaji), html.EscapeString(word.English)}
+		_, err := tx.Exec(`
+			INSERT INTO notes (id, guid, mid, mod, usn, tags, flds, sfld, csum, flags, data)
+			VALUES (?, ?, ?, ?, -1, ?, ?, ?, ?, 0, '')
+		`, id, "lang-portal-"+strconv.Itoa(word.ID), ankiNoteTypeID, mod, ankiTags(word.Groups),
+			strings.Join(fields, "\x1f"), word.Japanese, ankiChecksum(word.Japanese))
+		if err != nil {
+			return err
+		}
+
+		cardType, due, interval, factor := ankiCardNew, i+1, 0, 0
+		if word.DueAt != nil && word.IntervalDays != nil && *word.IntervalDays > 0 {
+			cardType, due, interval = ankiCardReview, daysSince(created, *word.DueAt), *word.IntervalDays
+			factor = int(*word.EaseFactor * 1000)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO cards (id, nid, did, ord, mod, usn, type, queue, due, ivl, factor, reps, lapses, left, odue, odid, flags, data)
+			VALUES (?, ?, ?, 0, ?, -1, ?, ?, ?, ?, ?, ?, ?, 0, 0, 0, 0, '')
+		`, id, id, deckID, mod, cardType, cardType, due, interval, factor,
+			word.CorrectCount+word.WrongCount, word.Lapses)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
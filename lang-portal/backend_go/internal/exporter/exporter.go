@@ -0,0 +1,142 @@
+// Package exporter writes vocabulary and review history out as CSV, JSON or
+// an Anki deck, so that learners can take their progress elsewhere.
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+)
+
+// Supported formats. Anki decks only hold words, not the review log.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatApkg = "apkg"
+)
+
+// FormatFromFilename guesses the format from a file's extension
+func FormatFromFilename(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV
+	case ".json":
+		return FormatJSON
+	case ".apkg":
+		return FormatApkg
+	}
+	return ""
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSON:
+		return "application/json; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+// WriteWords writes an export of words as CSV, JSON or an Anki package
+func WriteWords(w io.Writer, format string, export *models.WordExport) error {
+	switch format {
+	case FormatCSV:
+		return writeWordsCSV(w, export.Words)
+	case FormatJSON:
+		return writeJSON(w, export)
+	case FormatApkg:
+		return WriteApkg(w, export)
+	}
+	return fmt.Errorf("words cannot be exported as %q", format)
+}
+
+// WriteReviews writes the review log as CSV or JSON
+func WriteReviews(w io.Writer, format string, export *models.ReviewExport) error {
+	switch format {
+	case FormatCSV:
+		return writeReviewsCSV(w, export.Reviews)
+	case FormatJSON:
+		return writeJSON(w, export)
+	}
+	return fmt.Errorf("reviews cannot be exported as %q", format)
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// Optional values are written as empty cells
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func formatString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func writeWordsCSV(w io.Writer, words []models.ExportedWord) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"id", "japanese", "romaji", "english", "groups",
+		"correct_count", "wrong_count", "mastery", "lapses", "leech",
+		"ease_factor", "interval_days", "repetitions", "due_at", "last_reviewed_at",
+	})
+	for _, word := range words {
+		easeFactor := ""
+		if word.EaseFactor != nil {
+			easeFactor = strconv.FormatFloat(*word.EaseFactor, 'f', -1, 64)
+		}
+		writer.Write([]string{
+			strconv.Itoa(word.ID), word.Japanese, word.Romaji, word.English, strings.Join(word.Groups, "; "),
+			strconv.Itoa(word.CorrectCount), strconv.Itoa(word.WrongCount), word.Mastery,
+			strconv.Itoa(word.Lapses), strconv.FormatBool(word.Leech),
+			easeFactor, formatInt(word.IntervalDays), formatInt(word.Repetitions),
+			formatTime(word.DueAt), formatTime(word.LastReviewedAt),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeReviewsCSV(w io.Writer, reviews []models.ExportedReview) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"created_at", "word_id", "japanese", "romaji", "english",
+		"study_session_id", "group_id", "study_activity_id",
+		"correct", "grade", "direction", "answer", "response_ms",
+	})
+	for _, review := range reviews {
+		writer.Write([]string{
+			formatTime(&review.CreatedAt), strconv.Itoa(review.WordID), review.Japanese, review.Romaji, review.English,
+			strconv.Itoa(review.StudySessionID), strconv.Itoa(review.GroupID), formatInt(review.StudyActivityID),
+			strconv.FormatBool(review.Correct), formatString(review.Grade), formatString(review.Direction),
+			formatString(review.Answer), formatInt(review.ResponseMs),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
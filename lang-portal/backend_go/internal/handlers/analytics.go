@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"time"
+	"github.com/gin-gonic/gin"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
+)
+
+func GetReviewAnalytics(c *gin.Context) {
+	filter := services.AnalyticsFilter{Bucket: c.Query("bucket")}
+
+	for _, param := range []struct {
+		name string
+		dest *time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	} {
+		if value := c.Query(param.name); value != "" {
+			date, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid " + param.name + " format, expected YYYY-MM-DD"})
+				return
+			}
+			*param.dest = date
+		}
+	}
+
+	for _, param := range []struct {
+		name string
+		dest *int
+	}{
+		{"group_id", &filter.GroupID},
+		{"activity_id", &filter.ActivityID},
+	} {
+		if value := c.Query(param.name); value != "" {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid " + param.name + " format"})
+				return
+			}
+			*param.dest = id
+		}
+	}
+
+	analytics, err := services.NewAnalyticsService().GetReviewAnalytics(learnerID(c), filter)
+	if respondValidationError(c, err) {
+		return
+	}
+	switch err {
+	case nil:
+	case services.ErrGroupNotFound:
+		c.JSON(404, gin.H{"error": "Group not found"})
+		return
+	case services.ErrStudyActivityNotFound:
+		c.JSON(404, gin.H{"error": "Study activity not found"})
+		return
+	default:
+		log.Printf("Error getting review analytics: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, analytics)
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"github.com/gin-gonic/gin"
+	"github.com/mohawa/lang-portal/backend_go/internal/launchtoken"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
+)
+
+const (
+	// authCookie is the cookie browsers keep their sign-in token in
+	authCookie = "lang_portal_session"
+
+	// userKey is the context key Authenticate stores the learner under
+	userKey = "user"
+)
+
+type credentialsRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// isLaunchToken tells launch tokens, which are JWTs, apart from the opaque
+// sign-in tokens that share the Authorization header with them
+func isLaunchToken(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// authToken reads the sign-in token from the session cookie or else from an
+// "Authorization: Bearer" header
+func authToken(c *gin.Context) string {
+	if token, err := c.Cookie(authCookie); err == nil && token != "" {
+		return token
+	}
+	if token := bearerToken(c); !isLaunchToken(token) {
+		return token
+	}
+	return ""
+}
+
+// Authenticate works out which learner a request acts for. In single-user
+// mode that is always the local learner; otherwise it is whoever signed in
+// with the request's sign-in token. Requests without a valid token carry on
+// anonymously, for RequireLearner to turn away where a learner is needed.
+func Authenticate(multiUser bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service := services.NewAuthService()
+		var user *models.User
+		var err error
+		if !multiUser {
+			user, err = service.GetUser(services.LocalUserID)
+		} else if token := authToken(c); token != "" {
+			user, err = service.Authenticate(token)
+			if err == services.ErrAuthTokenInvalid {
+				user, err = nil, nil
+			}
+		}
+		if err != nil {
+			log.Printf("Error authenticating request: %v", err)
+			c.AbortWithStatusJSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if user != nil {
+			c.Set(userKey, user)
+		}
+		c.Next()
+	}
+}
+
+// currentUser returns the learner Authenticate found, or nil
+func currentUser(c *gin.Context) *models.User {
+	value, _ := c.Get(userKey)
+	user, _ := value.(*models.User)
+	return user
+}
+
+// learnerID returns the id of the learner a request acts for, or 0 when
+// nobody is signed in
+func learnerID(c *gin.Context) int {
+	if user := currentUser(c); user != nil {
+		return user.ID
+	}
+	return 0
+}
+
+// respondSignInRequired writes the 401 response for a request that needs a
+// signed-in learner
+func respondSignInRequired(c *gin.Context) {
+	c.Header("WWW-Authenticate", "Bearer")
+	c.AbortWithStatusJSON(401, gin.H{"error": "Sign in required"})
+}
+
+// RequireLearner turns away requests nobody is signed in for
+func RequireLearner(c *gin.Context) {
+	if learnerID(c) == 0 {
+		respondSignInRequired(c)
+		return
+	}
+	c.Next()
+}
+
+// RequireAdmin turns away requests from learners who do not administer the
+// portal. Run RequireLearner first so anonymous requests get a 401.
+func RequireAdmin(c *gin.Context) {
+	if user := currentUser(c); user == nil || !user.IsAdmin {
+		c.AbortWithStatusJSON(403, gin.H{"error": "Administrator access required"})
+		return
+	}
+	c.Next()
+}
+
+// sessionLearner returns the learner acting on a study session: the one a
+// launch token for it was issued to, or else the signed-in learner. It
+// writes the error response when there is neither.
+func sessionLearner(c *gin.Context, sessionID int) (int, *launchtoken.Claims, bool) {
+	if isLaunchToken(bearerToken(c)) {
+		claims, ok := authorizeLaunch(c, sessionID)
+		if !ok {
+			return 0, nil, false
+		}
+		return claims.UserID, claims, true
+	}
+	if id := learnerID(c); id != 0 {
+		return id, nil, true
+	}
+	respondSignInRequired(c)
+	return 0, nil, false
+}
+
+func Register(c *gin.Context) {
+	var req credentialsRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	user, err := services.NewAuthService().Register(req.Username, req.Password)
+	if respondValidationError(c, err) {
+		return
+	}
+	switch err {
+	case nil:
+	case services.ErrDuplicateUser:
+		c.JSON(409, gin.H{"error": "Username is already taken"})
+		return
+	default:
+		log.Printf("Error registering user %q: %v", req.Username, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Registered user %d (%s)", user.ID, user.Username)
+	c.JSON(201, user)
+}
+
+// Login signs a user in. The sign-in token is set as an HttpOnly cookie for
+// browsers and returned for clients that send it as a bearer token.
+func Login(c *gin.Context) {
+	var req credentialsRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	session, err := services.NewAuthService().Login(req.Username, req.Password)
+	if err == services.ErrInvalidCredentials {
+		c.JSON(401, gin.H{"error": "Invalid username or password"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error signing in user %q: %v", req.Username, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(authCookie, session.Token, int(time.Until(session.ExpiresAt).Seconds()), "/", "", c.Request.TLS != nil, true)
+	c.JSON(200, session)
+}
+
+// Logout revokes the request's sign-in token and clears the session cookie
+func Logout(c *gin.Context) {
+	if token := authToken(c); token != "" {
+		if err := services.NewAuthService().Logout(token); err != nil {
+			log.Printf("Error signing out: %v", err)
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(authCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": "Signed out",
+	})
+}
+
+// GetCurrentUser returns the learner the request acts for
+func GetCurrentUser(c *gin.Context) {
+	c.JSON(200, currentUser(c))
+}
@@ -1,28 +1,122 @@
 package handlers
 
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"github.com/gin-gonic/gin"
+	"github.com/mohawa/lang-portal/backend_go/internal/launchtoken"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
+)
+
+// ItemsPerPage is the page size used by every paginated list endpoint
+const ItemsPerPage = 100
+
 type PaginatedResponse struct {
-    Items      interface{} `json:"items"`
-    Pagination struct {
-        CurrentPage  int `json:"current_page"`
-        TotalPages   int `json:"total_pages"`
-        TotalItems   int `json:"total_items"`
-        ItemsPerPage int `json:"items_per_page"`
-    } `json:"pagination"`
+	Items      interface{} `json:"items"`
+	Pagination struct {
+		CurrentPage  int `json:"current_page"`
+		TotalPages   int `json:"total_pages"`
+		TotalItems   int `json:"total_items"`
+		ItemsPerPage int `json:"items_per_page"`
+	} `json:"pagination"`
 }
 
 func EmptyPaginatedResponse() PaginatedResponse {
-    return PaginatedResponse{
-        Items: []interface{}{},  // Empty array instead of null
-        Pagination: struct {
-            CurrentPage  int `json:"current_page"`
-            TotalPages   int `json:"total_pages"`
-            TotalItems   int `json:"total_items"`
-            ItemsPerPage int `json:"items_per_page"`
-        }{
-            CurrentPage:  1,
-            TotalPages:   0,
-            TotalItems:   0,
-            ItemsPerPage: 100,
-        },
-    }
-} 
\ No newline at end of file
+	return PaginatedResponse{
+		Items: []interface{}{}, // Empty array instead of null
+		Pagination: struct {
+			CurrentPage  int `json:"current_page"`
+			TotalPages   int `json:"total_pages"`
+			TotalItems   int `json:"total_items"`
+			ItemsPerPage int `json:"items_per_page"`
+		}{
+			CurrentPage:  1,
+			TotalPages:   0,
+			TotalItems:   0,
+			ItemsPerPage: ItemsPerPage,
+		},
+	}
+}
+
+// getPage reads the 1-based page query parameter, defaulting to the first page
+func getPage(c *gin.Context) int {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		return 1
+	}
+	return page
+}
+
+// getSort reads the sort_by and order query parameters
+func getSort(c *gin.Context, defaultSortBy string) (string, string) {
+	return c.DefaultQuery("sort_by", defaultSortBy), c.DefaultQuery("order", "asc")
+}
+
+// getBoolQuery reads an optional boolean query parameter such as ?include_descendants=true
+func getBoolQuery(c *gin.Context, name string) bool {
+	value, err := strconv.ParseBool(c.Query(name))
+	return err == nil && value
+}
+
+// respondValidationError writes a 422 response when err is a validation
+// error and reports whether it did so
+func respondValidationError(c *gin.Context, err error) bool {
+	var validationErr *services.ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+	c.JSON(422, gin.H{
+		"error": validationErr.Error(),
+		"field": validationErr.Field,
+	})
+	return true
+}
+
+// bearerToken reads the token of an "Authorization: Bearer" header
+func bearerToken(c *gin.Context) string {
+	scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// respondLaunchTokenError writes a 401 response when err is a missing or
+// invalid launch token, or a 403 when the token is for another session, and
+// reports whether it did
+func respondLaunchTokenError(c *gin.Context, err error) bool {
+	switch err {
+	case services.ErrLaunchTokenRequired:
+		c.Header("WWW-Authenticate", "Bearer")
+		c.JSON(401, gin.H{"error": "Launch token required"})
+	case services.ErrLaunchTokenInvalid:
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(401, gin.H{"error": "Launch token is invalid or has expired"})
+	case services.ErrLaunchTokenScope:
+		c.JSON(403, gin.H{"error": "Launch token is for another study session"})
+	default:
+		return false
+	}
+	return true
+}
+
+// authorizeLaunch checks that the request carries a launch token for the
+// study session, writing the error response when it does not
+func authorizeLaunch(c *gin.Context, sessionID int) (*launchtoken.Claims, bool) {
+	claims, err := services.NewStudyService().VerifyLaunchToken(bearerToken(c), sessionID)
+	if respondLaunchTokenError(c, err) {
+		return nil, false
+	}
+	if err == services.ErrStudySessionNotFound {
+		c.JSON(404, gin.H{"error": "Study session not found"})
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error verifying launch token for study session %d: %v", sessionID, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return claims, true
+}
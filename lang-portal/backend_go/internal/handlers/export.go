@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"strconv"
+	"strings"
+	"github.com/gin-gonic/gin"
+	"github.com/mohawa/lang-portal/backend_go/internal/exporter"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
+)
+
+// exportGroupID reads the optional group_id query parameter
+func exportGroupID(c *gin.Context) (int, bool) {
+	value := c.Query("group_id")
+	if value == "" {
+		return 0, true
+	}
+	groupID, err := strconv.Atoi(value)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid group_id format"})
+		return 0, false
+	}
+	return groupID, true
+}
+
+// sendExport sends an export as a file download named after its contents
+func sendExport(c *gin.Context, name, format string, data []byte) {
+	c.Header("Content-Disposition", `attachment; filename="`+name+"."+format+`"`)
+	c.Data(200, exporter.ContentType(format), data)
+}
+
+// ExportWords downloads words with their stats as CSV (default), JSON or an
+// Anki deck, optionally only those in a group
+func ExportWords(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", exporter.FormatCSV))
+	if format != exporter.FormatCSV && format != exporter.FormatJSON && format != exporter.FormatApkg {
+		c.JSON(400, gin.H{"error": "format must be one of csv, json or apkg"})
+		return
+	}
+	groupID, ok := exportGroupID(c)
+	if !ok {
+		return
+	}
+
+	export, err := services.NewExportService().ExportWords(learnerID(c), groupID)
+	if err == services.ErrGroupNotFound {
+		c.JSON(404, gin.H{"error": "Group not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error exporting words: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := exporter.WriteWords(&buf, format, export); err != nil {
+		log.Printf("Error writing %s word export: %v", format, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := "words"
+	if groupID != 0 {
+		name = "words-group-" + strconv.Itoa(groupID)
+	}
+	sendExport(c, name, format, buf.Bytes())
+}
+
+// ExportReviews downloads the review log as CSV (default) or JSON, optionally
+// only the reviews made in sessions of a group
+func ExportReviews(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", exporter.FormatCSV))
+	if format != exporter.FormatCSV && format != exporter.FormatJSON {
+		c.JSON(400, gin.H{"error": "format must be csv or json"})
+		return
+	}
+	groupID, ok := exportGroupID(c)
+	if !ok {
+		return
+	}
+
+	export, err := services.NewExportService().ExportReviews(learnerID(c), groupID)
+	if err == services.ErrGroupNotFound {
+		c.JSON(404, gin.H{"error": "Group not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error exporting reviews: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := exporter.WriteReviews(&buf, format, export); err != nil {
+		log.Printf("Error writing %s review export: %v", format, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := "reviews"
+	if groupID != 0 {
+		name = "reviews-group-" + strconv.Itoa(groupID)
+	}
+	sendExport(c, name, format, buf.Bytes())
+}
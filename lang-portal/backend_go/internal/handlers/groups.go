@@ -1,43 +1,330 @@
 package handlers
 
 import (
-	"github.com/gin-gonic/gin"
+	"log"
 	"strconv"
+	"github.com/gin-gonic/gin"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
 )
 
+// maxRecentSessions caps how many sessions recent accuracy may cover
+const maxRecentSessions = 50
+
+// getRecentSessions reads ?recent_sessions=, how many of a group's latest
+// sessions its recent accuracy covers, writing a 400 response when invalid
+func getRecentSessions(c *gin.Context) (int, bool) {
+	value := c.Query("recent_sessions")
+	if value == "" {
+		return services.DefaultRecentSessions, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		c.JSON(400, gin.H{"error": "recent_sessions must be a positive integer"})
+		return 0, false
+	}
+	if n > maxRecentSessions {
+		n = maxRecentSessions
+	}
+	return n, true
+}
+
 func GetGroup(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(400, gin.H{"error": "Invalid ID format"})
 		return
 	}
+	recentSessions, ok := getRecentSessions(c)
+	if !ok {
+		return
+	}
 
-	// For testing, only return data for group ID 1
-	if id != 1 {
+	group, err := services.NewGroupService().GetGroup(learnerID(c), id, getBoolQuery(c, "include_descendants"), recentSessions)
+	if err == services.ErrGroupNotFound {
 		c.JSON(404, gin.H{"error": "Group not found"})
 		return
 	}
+	if err != nil {
+		log.Printf("Error getting group %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(200, gin.H{
-		"id":   id,
-		"name": "Basic Greetings",
-		"stats": gin.H{
-			"total_word_count": 5,
-		},
-	})
+	c.JSON(200, group)
 }
 
 func GetGroups(c *gin.Context) {
-	response := EmptyPaginatedResponse()
+	sortBy, order := getSort(c, "name")
+
+	var parentID *int
+	if value := c.Query("parent_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid parent_id format"})
+			return
+		}
+		parentID = &id
+	}
+	recentSessions, ok := getRecentSessions(c)
+	if !ok {
+		return
+	}
+
+	response, err := services.NewGroupService().GetGroups(learnerID(c), getPage(c), ItemsPerPage, sortBy, order, parentID, recentSessions)
+	if err != nil {
+		log.Printf("Error getting groups: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(200, response)
 }
 
 func GetGroupWords(c *gin.Context) {
-	response := EmptyPaginatedResponse()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	sortBy, order := getSort(c, "japanese")
+
+	response, err := services.NewGroupService().GetGroupWords(learnerID(c), id, getPage(c), ItemsPerPage, sortBy, order,
+		getBoolQuery(c, "include_descendants"))
+	if err == services.ErrGroupNotFound {
+		c.JSON(404, gin.H{"error": "Group not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting words for group %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(200, response)
 }
 
 func GetGroupStudySessions(c *gin.Context) {
-	response := EmptyPaginatedResponse()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	response, err := services.NewGroupService().GetGroupStudySessions(learnerID(c), id, getPage(c), ItemsPerPage,
+		getBoolQuery(c, "include_descendants"))
+	if err == services.ErrGroupNotFound {
+		c.JSON(404, gin.H{"error": "Group not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting study sessions for group %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(200, response)
-} 
\ No newline at end of file
+}
+
+type groupWordsRequest struct {
+	WordIDs []int `json:"word_ids"`
+}
+
+func AddGroupWords(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var req groupWordsRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if len(req.WordIDs) == 0 {
+		c.JSON(400, gin.H{"error": "word_ids is required"})
+		return
+	}
+
+	added, err := services.NewGroupService().AddWordsToGroup(id, req.WordIDs)
+	if respondValidationError(c, err) {
+		return
+	}
+	if err == services.ErrGroupNotFound {
+		c.JSON(404, gin.H{"error": "Group not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error adding words to group %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"added":   added,
+	})
+}
+
+func RemoveGroupWords(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var req groupWordsRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if len(req.WordIDs) == 0 {
+		c.JSON(400, gin.H{"error": "word_ids is required"})
+		return
+	}
+
+	removed, err := services.NewGroupService().RemoveWordsFromGroup(id, req.WordIDs)
+	if err == services.ErrGroupNotFound {
+		c.JSON(404, gin.H{"error": "Group not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error removing words from group %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"removed": removed,
+	})
+}
+
+type groupRequest struct {
+	Name     string `json:"name"`
+	ParentID *int   `json:"parent_id"`
+}
+
+func CreateGroup(c *gin.Context) {
+	var req groupRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	group, err := services.NewGroupService().CreateGroup(req.Name, req.ParentID)
+	if respondValidationError(c, err) {
+		return
+	}
+	if err == services.ErrDuplicateGroup {
+		c.JSON(409, gin.H{"error": "A group with this name already exists"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating group: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(201, group)
+}
+
+func UpdateGroup(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var req groupRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	group, err := services.NewGroupService().UpdateGroup(id, req.Name, req.ParentID)
+	if respondValidationError(c, err) {
+		return
+	}
+	switch err {
+	case nil:
+	case services.ErrGroupNotFound:
+		c.JSON(404, gin.H{"error": "Group not found"})
+		return
+	case services.ErrDuplicateGroup:
+		c.JSON(409, gin.H{"error": "A group with this name already exists"})
+		return
+	default:
+		log.Printf("Error updating group %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, group)
+}
+
+func DeleteGroup(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	err = services.NewGroupService().DeleteGroup(id)
+	switch err {
+	case nil:
+	case services.ErrGroupNotFound:
+		c.JSON(404, gin.H{"error": "Group not found"})
+		return
+	case services.ErrGroupHasSessions:
+		c.JSON(409, gin.H{"error": "Group has study sessions; merge it into another group instead"})
+		return
+	default:
+		log.Printf("Error deleting group %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": "Group has been deleted",
+	})
+}
+
+func MergeGroup(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var req struct {
+		TargetGroupID int `json:"target_group_id"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if req.TargetGroupID == 0 {
+		c.JSON(400, gin.H{"error": "target_group_id is required"})
+		return
+	}
+
+	group, err := services.NewGroupService().MergeGroups(learnerID(c), id, req.TargetGroupID)
+	if respondValidationError(c, err) {
+		return
+	}
+	if err == services.ErrGroupNotFound {
+		c.JSON(404, gin.H{"error": "Group not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error merging group %d into %d: %v", id, req.TargetGroupID, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Merged group %d into %d", id, req.TargetGroupID)
+	c.JSON(200, group)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"github.com/gin-gonic/gin"
+	"github.com/mohawa/lang-portal/backend_go/internal/importer"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
+)
+
+// maxImportBytes caps the size of an uploaded import file
+const maxImportBytes = 20 << 20
+
+// ImportWords previews or commits a vocabulary import uploaded as the file
+// field of a multipart form
+func ImportWords(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes+1<<20)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Upload the vocabulary as the file field of a multipart form"})
+		return
+	}
+	if header.Size > maxImportBytes {
+		c.JSON(413, gin.H{"error": "Import files are limited to 20 MB"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		log.Printf("Error opening import upload: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		log.Printf("Error reading import upload: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	options := services.ImportOptions{
+		Format:     c.PostForm("format"),
+		HasHeader:  true,
+		GroupName:  c.PostForm("group"),
+		OnConflict: c.PostForm("on_conflict"),
+	}
+	if options.Format == "" {
+		options.Format = importer.FormatFromFilename(header.Filename)
+	}
+	if value := c.PostForm("has_header"); value != "" {
+		if options.HasHeader, err = strconv.ParseBool(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid has_header format"})
+			return
+		}
+	}
+	if value := c.PostForm("commit"); value != "" {
+		if options.Commit, err = strconv.ParseBool(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid commit format"})
+			return
+		}
+	}
+	if value := c.PostForm("group_id"); value != "" {
+		if options.GroupID, err = strconv.Atoi(value); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid group_id format"})
+			return
+		}
+	}
+	if value := c.PostForm("mapping"); value != "" {
+		if err := json.Unmarshal([]byte(value), &options.Mapping); err != nil {
+			c.JSON(400, gin.H{"error": "mapping must be a JSON object of source field to column"})
+			return
+		}
+	}
+
+	result, err := services.NewImportService().ImportWords(data, options)
+	if respondValidationError(c, err) {
+		return
+	}
+	if err == services.ErrGroupNotFound {
+		c.JSON(404, gin.H{"error": "Group not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error importing %s: %v", header.Filename, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, result)
+}
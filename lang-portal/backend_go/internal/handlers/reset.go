@@ -3,63 +3,51 @@ package handlers
 import (
 	"log"
 	"github.com/gin-gonic/gin"
-	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
 )
 
+// ResetHistory deletes the study history of the learner making the request
 func ResetHistory(c *gin.Context) {
-	log.Printf("ResetHistory called with method: %s", c.Request.Method)
+	userID := learnerID(c)
 
-	// First verify database connection
-	if database.DB == nil {
-		log.Printf("Error: database.DB is nil")
-		c.JSON(500, gin.H{"error": "Database connection not initialized"})
-		return
-	}
-
-	// Begin transaction
-	tx, err := database.DB.Begin()
+	result, err := services.NewResetService().ResetHistory(userID)
 	if err != nil {
-		log.Printf("Error starting transaction: %v", err)
-		c.JSON(500, gin.H{"error": "Failed to start reset operation"})
-		return
-	}
-
-	// Delete all word review items
-	result, err := tx.Exec("DELETE FROM word_review_items")
-	if err != nil {
-		tx.Rollback()
-		log.Printf("Error deleting word review items: %v", err)
+		log.Printf("Error resetting study history for user %d: %v", userID, err)
 		c.JSON(500, gin.H{"error": "Failed to reset study history"})
 		return
 	}
-	reviewsDeleted, _ := result.RowsAffected()
 
-	// Delete all study sessions
-	result, err = tx.Exec("DELETE FROM study_sessions")
+	log.Printf("Study history reset successful for user %d. Deleted %d review items, %d study sessions and %d xAPI statements",
+		userID, result.ReviewsDeleted, result.StudySessionsDeleted, result.StatementsDeleted)
+
+	c.JSON(200, gin.H{
+		"success":                true,
+		"message":                "Study history has been reset",
+		"reviews_deleted":        result.ReviewsDeleted,
+		"study_sessions_deleted": result.StudySessionsDeleted,
+		"statements_deleted":     result.StatementsDeleted,
+	})
+}
+
+// FullReset deletes the study history of every learner: study sessions,
+// reviews, review schedules and xAPI statements. Unlike the old system reset
+// it keeps accounts, words, groups and study activities
+func FullReset(c *gin.Context) {
+	result, err := services.NewResetService().FullReset()
 	if err != nil {
-		tx.Rollback()
-		log.Printf("Error deleting study sessions: %v", err)
+		log.Printf("Error resetting all study history: %v", err)
 		c.JSON(500, gin.H{"error": "Failed to reset study history"})
 		return
 	}
-	sessionsDeleted, _ := result.RowsAffected()
 
-	// Commit transaction
-	if err = tx.Commit(); err != nil {
-		log.Printf("Error committing transaction: %v", err)
-		c.JSON(500, gin.H{"error": "Failed to complete reset"})
-		return
-	}
+	log.Printf("Full reset successful. Deleted %d review items, %d study sessions and %d xAPI statements",
+		result.ReviewsDeleted, result.StudySessionsDeleted, result.StatementsDeleted)
 
-	log.Printf("Study history reset successful. Deleted %d review items and %d study sessions", 
-		reviewsDeleted, sessionsDeleted)
-	
 	c.JSON(200, gin.H{
-		"success": true,
-		"message": "Study history has been reset",
+		"success":                true,
+		"message":                "Study history has been reset for every learner",
+		"reviews_deleted":        result.ReviewsDeleted,
+		"study_sessions_deleted": result.StudySessionsDeleted,
+		"statements_deleted":     result.StatementsDeleted,
 	})
 }
-
-func FullReset(c *gin.Context) {
-	// ... FullReset remains the same ...
-} 
\ No newline at end of file
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"github.com/gin-gonic/gin"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
+)
+
+const (
+	defaultReviewQueueLimit = 20
+	maxReviewQueueLimit     = 100
+)
+
+func GetReviewQueue(c *gin.Context) {
+	groupID := 0
+	if value := c.Query("group_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid group_id format"})
+			return
+		}
+		groupID = id
+	}
+
+	limit := defaultReviewQueueLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			c.JSON(400, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = n
+	}
+	if limit > maxReviewQueueLimit {
+		limit = maxReviewQueueLimit
+	}
+
+	items, err := services.NewSRSService().GetReviewQueue(learnerID(c), groupID, limit)
+	if err == services.ErrGroupNotFound {
+		c.JSON(404, gin.H{"error": "Group not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting review queue: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"items": items})
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"log"
+	"github.com/gin-gonic/gin"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
+)
+
+type settingsRequest struct {
+	Timezone         *string `json:"timezone"`
+	DailyGoalReviews *int    `json:"daily_goal_reviews"`
+	DailyGoalMinutes *int    `json:"daily_goal_minutes"`
+	StreakFreezes    *int    `json:"streak_freezes"`
+}
+
+func GetSettings(c *gin.Context) {
+	settings, err := services.NewSettingsService().GetSettings(learnerID(c))
+	if err != nil {
+		log.Printf("Error getting settings: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, settings)
+}
+
+func UpdateSettings(c *gin.Context) {
+	var req settingsRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	settings, err := services.NewSettingsService().UpdateSettings(learnerID(c), services.SettingsUpdate{
+		Timezone:         req.Timezone,
+		DailyGoalReviews: req.DailyGoalReviews,
+		DailyGoalMinutes: req.DailyGoalMinutes,
+		StreakFreezes:    req.StreakFreezes,
+	})
+	if respondValidationError(c, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating settings: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, settings)
+}
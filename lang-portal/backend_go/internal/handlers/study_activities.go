@@ -1,10 +1,33 @@
 package handlers
 
 import (
+	"log"
 	"github.com/gin-gonic/gin"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
 	"strconv"
 )
 
+func GetStudyActivities(c *gin.Context) {
+	var enabled *bool
+	if value := c.Query("enabled"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid enabled format"})
+			return
+		}
+		enabled = &parsed
+	}
+
+	response, err := services.NewStudyActivityService().GetStudyActivities(getPage(c), ItemsPerPage, enabled)
+	if err != nil {
+		log.Printf("Error getting study activities: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, response)
+}
+
 func GetStudyActivity(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -12,25 +35,43 @@ func GetStudyActivity(c *gin.Context) {
 		return
 	}
 
-	// For testing, only return data for activity ID 1
-	if id != 1 {
+	activity, err := services.NewStudyActivityService().GetStudyActivity(id)
+	if err == services.ErrStudyActivityNotFound {
 		c.JSON(404, gin.H{"error": "Study activity not found"})
 		return
 	}
+	if err != nil {
+		log.Printf("Error getting study activity %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(200, gin.H{
-		"id":            id,
-		"name":          "Flashcards",
-		"thumbnail_url": "/images/flashcards.png",
-		"description":   "Practice words using flashcards",
-	})
+	c.JSON(200, activity)
 }
 
 func GetStudyActivitySessions(c *gin.Context) {
-	response := EmptyPaginatedResponse()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	response, err := services.NewStudyActivityService().GetStudyActivitySessions(learnerID(c), id, getPage(c), ItemsPerPage)
+	if err == services.ErrStudyActivityNotFound {
+		c.JSON(404, gin.H{"error": "Study activity not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting study sessions of study activity %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(200, response)
 }
 
+// CreateStudyActivity launches a study activity for a group by starting a
+// study session in it
 func CreateStudyActivity(c *gin.Context) {
 	var req struct {
 		GroupID         int `json:"group_id"`
@@ -47,8 +88,119 @@ func CreateStudyActivity(c *gin.Context) {
 		return
 	}
 
-	c.JSON(201, gin.H{
-		"id":       1,
-		"group_id": req.GroupID,
+	session, err := services.NewStudyService().CreateStudyActivity(learnerID(c), req.GroupID, req.StudyActivityID)
+	switch err {
+	case nil:
+	case services.ErrGroupNotFound:
+		c.JSON(422, gin.H{"error": "Group does not exist"})
+		return
+	case services.ErrStudyActivityNotFound:
+		c.JSON(422, gin.H{"error": "Study activity does not exist"})
+		return
+	case services.ErrStudyActivityDisabled:
+		c.JSON(422, gin.H{"error": "Study activity is disabled"})
+		return
+	default:
+		log.Printf("Error creating study session: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"id":                      session.ID,
+		"group_id":                session.GroupID,
+		"launch_token":            session.LaunchToken.Token,
+		"launch_token_expires_at": session.LaunchToken.ExpiresAt,
+	}
+	if session.LaunchURL != "" {
+		response["launch_url"] = session.LaunchURL
+	}
+	c.JSON(201, response)
+}
+
+// RegisterStudyActivity adds a study activity to the registry
+func RegisterStudyActivity(c *gin.Context) {
+	var req services.StudyActivityInput
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	activity, err := services.NewStudyActivityService().CreateStudyActivity(req)
+	if respondValidationError(c, err) {
+		return
+	}
+	if err == services.ErrDuplicateStudyActivity {
+		c.JSON(409, gin.H{"error": "A study activity with this name already exists"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating study activity: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(201, activity)
+}
+
+func UpdateStudyActivity(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var req services.StudyActivityInput
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	activity, err := services.NewStudyActivityService().UpdateStudyActivity(id, req)
+	if respondValidationError(c, err) {
+		return
+	}
+	switch err {
+	case nil:
+	case services.ErrStudyActivityNotFound:
+		c.JSON(404, gin.H{"error": "Study activity not found"})
+		return
+	case services.ErrDuplicateStudyActivity:
+		c.JSON(409, gin.H{"error": "A study activity with this name already exists"})
+		return
+	default:
+		log.Printf("Error updating study activity %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, activity)
+}
+
+func DeleteStudyActivity(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	err = services.NewStudyActivityService().DeleteStudyActivity(id)
+	switch err {
+	case nil:
+	case services.ErrStudyActivityNotFound:
+		c.JSON(404, gin.H{"error": "Study activity not found"})
+		return
+	case services.ErrStudyActivityHasSessions:
+		c.JSON(409, gin.H{"error": "Study activity has study sessions; disable it instead"})
+		return
+	default:
+		log.Printf("Error deleting study activity %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"success": true,
+		"message": "Study activity has been deleted",
 	})
-} 
\ No newline at end of file
+}
@@ -1,12 +1,23 @@
 package handlers
 
 import (
-	"github.com/gin-gonic/gin"
+	"log"
 	"strconv"
+	"strings"
+	"github.com/gin-gonic/gin"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
+	"github.com/mohawa/lang-portal/backend_go/internal/srs"
 )
 
 func GetStudySessions(c *gin.Context) {
-	response := EmptyPaginatedResponse()
+	response, err := services.NewStudyService().GetStudySessions(learnerID(c), getPage(c), ItemsPerPage)
+	if err != nil {
+		log.Printf("Error getting study sessions: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(200, response)
 }
 
@@ -17,28 +28,262 @@ func GetStudySession(c *gin.Context) {
 		return
 	}
 
-	// For testing, only return data for session ID 1
-	if id != 1 {
+	session, err := services.NewStudyService().GetStudySession(learnerID(c), id)
+	if err == services.ErrStudySessionNotFound {
 		c.JSON(404, gin.H{"error": "Study session not found"})
 		return
 	}
+	if err != nil {
+		log.Printf("Error getting study session %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(200, gin.H{
-		"id":                 id,
-		"activity_name":      "Flashcards",
-		"group_name":         "Basic Greetings",
-		"start_time":         "2025-03-01T13:53:24Z",
-		"end_time":          "2025-03-01T14:53:24Z",
-		"review_items_count": 5,
-	})
+	c.JSON(200, session)
+}
+
+func GetStudySessionWords(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	response, err := services.NewStudyService().GetStudySessionWords(learnerID(c), id, getPage(c), ItemsPerPage)
+	if err == services.ErrStudySessionNotFound {
+		c.JSON(404, gin.H{"error": "Study session not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting words for study session %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, response)
 }
 
 func ReviewWord(c *gin.Context) {
-	c.JSON(200, gin.H{
-		"success":          true,
-		"word_id":         1,
-		"study_session_id": 1,
-		"correct":         true,
-		"created_at":      "2025-03-01T14:52:59Z",
-	})
-} 
\ No newline at end of file
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid study session ID format"})
+		return
+	}
+	wordID, err := strconv.Atoi(c.Param("word_id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid word ID format"})
+		return
+	}
+	if _, ok := authorizeLaunch(c, sessionID); !ok {
+		return
+	}
+
+	var req struct {
+		Correct    *bool  `json:"correct"`
+		Grade      string `json:"grade"`
+		ResponseMs *int   `json:"response_ms"`
+		Answer     string `json:"answer"`
+		Direction  string `json:"direction"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if req.Correct == nil && req.Grade == "" {
+		// A typed Japanese answer can be checked here instead of by the client
+		if req.Answer == "" || (req.Direction != models.DirectionEnglishToJapanese && req.Direction != models.DirectionAudioToJapanese) {
+			c.JSON(400, gin.H{"error": "correct or grade is required"})
+			return
+		}
+		correct, err := services.NewWordService().CheckReading(wordID, req.Answer)
+		if err == services.ErrWordNotFound {
+			c.JSON(404, gin.H{"error": "Word not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error checking answer for word %d: %v", wordID, err)
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		req.Correct = &correct
+	}
+	if req.Grade != "" {
+		grade := srs.Grade(req.Grade)
+		if !grade.Valid() {
+			c.JSON(400, gin.H{"error": "grade must be one of again, hard, good, easy"})
+			return
+		}
+		if req.Correct != nil && *req.Correct != grade.Correct() {
+			c.JSON(400, gin.H{"error": "correct contradicts grade"})
+			return
+		}
+	}
+	if req.ResponseMs != nil && *req.ResponseMs < 0 {
+		c.JSON(400, gin.H{"error": "response_ms must not be negative"})
+		return
+	}
+	switch req.Direction {
+	case "", models.DirectionJapaneseToEnglish, models.DirectionEnglishToJapanese, models.DirectionAudioToJapanese:
+	default:
+		c.JSON(400, gin.H{"error": "direction must be one of jp_en, en_jp, audio_jp"})
+		return
+	}
+
+	input := models.WordReviewItem{
+		Grade:      req.Grade,
+		ResponseMs: req.ResponseMs,
+		Answer:     req.Answer,
+		Direction:  req.Direction,
+	}
+	if req.Correct != nil {
+		input.Correct = *req.Correct
+	}
+
+	review, err := services.NewStudyService().ReviewWord(sessionID, wordID, input)
+	switch err {
+	case nil:
+	case services.ErrStudySessionNotFound:
+		c.JSON(404, gin.H{"error": "Study session not found"})
+		return
+	case services.ErrStudySessionEnded:
+		c.JSON(409, gin.H{"error": "Study session has ended"})
+		return
+	case services.ErrWordNotFound:
+		c.JSON(404, gin.H{"error": "Word not found"})
+		return
+	default:
+		log.Printf("Error reviewing word %d in session %d: %v", wordID, sessionID, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, struct {
+		Success bool `json:"success"`
+		*models.WordReviewItem
+	}{true, review})
+}
+
+func AnswerWord(c *gin.Context) {
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid study session ID format"})
+		return
+	}
+	wordID, err := strconv.Atoi(c.Param("word_id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid word ID format"})
+		return
+	}
+	if _, ok := authorizeLaunch(c, sessionID); !ok {
+		return
+	}
+
+	var req struct {
+		Answer     string `json:"answer"`
+		Direction  string `json:"direction"`
+		ResponseMs *int   `json:"response_ms"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if strings.TrimSpace(req.Answer) == "" {
+		c.JSON(400, gin.H{"error": "answer is required"})
+		return
+	}
+	if req.ResponseMs != nil && *req.ResponseMs < 0 {
+		c.JSON(400, gin.H{"error": "response_ms must not be negative"})
+		return
+	}
+	switch req.Direction {
+	case "", models.DirectionJapaneseToEnglish, models.DirectionEnglishToJapanese, models.DirectionAudioToJapanese:
+	default:
+		c.JSON(400, gin.H{"error": "direction must be one of jp_en, en_jp, audio_jp"})
+		return
+	}
+
+	result, err := services.NewStudyService().AnswerWord(sessionID, wordID, req.Answer, req.Direction, req.ResponseMs)
+	switch err {
+	case nil:
+	case services.ErrStudySessionNotFound:
+		c.JSON(404, gin.H{"error": "Study session not found"})
+		return
+	case services.ErrStudySessionEnded:
+		c.JSON(409, gin.H{"error": "Study session has ended"})
+		return
+	case services.ErrWordNotFound:
+		c.JSON(404, gin.H{"error": "Word not found"})
+		return
+	default:
+		log.Printf("Error checking answer for word %d in session %d: %v", wordID, sessionID, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, result)
+}
+
+func HeartbeatStudySession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	// A launched app's heartbeat also renews its launch token
+	userID, claims, ok := sessionLearner(c, id)
+	if !ok {
+		return
+	}
+
+	service := services.NewStudyService()
+	session, err := service.Heartbeat(userID, id)
+	if err != nil || claims == nil {
+		respondSessionLifecycle(c, id, session, err)
+		return
+	}
+	token, err := service.RenewLaunchToken(claims)
+	if err != nil {
+		log.Printf("Error renewing launch token for study session %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, struct {
+		*models.StudySessionResponse
+		*models.LaunchToken
+	}{session, token})
+}
+
+func EndStudySession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	userID, _, ok := sessionLearner(c, id)
+	if !ok {
+		return
+	}
+
+	session, err := services.NewStudyService().EndStudySession(userID, id)
+	if err == nil {
+		log.Printf("Ended study session %d after %ds", id, session.DurationSeconds)
+	}
+	respondSessionLifecycle(c, id, session, err)
+}
+
+func respondSessionLifecycle(c *gin.Context, id int, session *models.StudySessionResponse, err error) {
+	switch err {
+	case nil:
+		c.JSON(200, session)
+	case services.ErrStudySessionNotFound:
+		c.JSON(404, gin.H{"error": "Study session not found"})
+	case services.ErrStudySessionEnded:
+		c.JSON(409, gin.H{"error": "Study session has ended"})
+	default:
+		log.Printf("Error updating study session %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
@@ -3,67 +3,67 @@ package handlers
 import (
 	"log"
 	"strconv"
+	"strings"
 	"github.com/gin-gonic/gin"
-	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
+	"github.com/mohawa/lang-portal/backend_go/internal/srs"
 )
 
 func GetWords(c *gin.Context) {
-	log.Printf("Getting words from database...")
-	
-	// First, verify database connection
-	if database.DB == nil {
-		log.Printf("Error: database.DB is nil")
-		c.JSON(500, gin.H{"error": "Database connection not initialized"})
+	mastery := srs.Mastery(c.Query("mastery"))
+	if mastery != "" && !mastery.Valid() {
+		c.JSON(400, gin.H{"error": "mastery must be one of new, learning, young, mature or mastered"})
 		return
 	}
 
-	// Query all words
-	rows, err := database.DB.Query("SELECT id, japanese, romaji, english FROM words")
+	// Search results are ordered by relevance unless a sort is asked for
+	if query := strings.TrimSpace(c.Query("q")); query != "" {
+		sortBy, order := getSort(c, "")
+		response, err := services.NewSearchService().SearchWords(learnerID(c), query, getPage(c), ItemsPerPage, sortBy, order, mastery)
+		if err != nil {
+			log.Printf("Error searching words for %q: %v", query, err)
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, response)
+		return
+	}
+
+	sortBy, order := getSort(c, "id")
+	response, err := services.NewWordService().GetWords(learnerID(c), getPage(c), ItemsPerPage, sortBy, order, mastery)
 	if err != nil {
-		log.Printf("Error querying words: %v", err)
+		log.Printf("Error getting words: %v", err)
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
 
-	// Initialize empty slice for words
-	words := make([]gin.H, 0)
+	c.JSON(200, response)
+}
 
-	// Scan rows into words slice
-	for rows.Next() {
-		var id int
-		var japanese, romaji, english string
-		if err := rows.Scan(&id, &japanese, &romaji, &english); err != nil {
-			log.Printf("Error scanning word: %v", err)
-			c.JSON(500, gin.H{"error": err.Error()})
+func GetLeeches(c *gin.Context) {
+	groupID := 0
+	if value := c.Query("group_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid group_id format"})
 			return
 		}
-		log.Printf("Found word: %d, %s (%s) - %s", id, japanese, romaji, english)
-		words = append(words, gin.H{
-			"id":       id,
-			"japanese": japanese,
-			"romaji":   romaji,
-			"english":  english,
-		})
-	}
-
-	// Check for errors from iterating over rows
-	if err = rows.Err(); err != nil {
-		log.Printf("Error iterating over words: %v", err)
+		groupID = id
+	}
+
+	response, err := services.NewWordService().GetLeeches(learnerID(c), groupID, getPage(c), ItemsPerPage)
+	if err == services.ErrGroupNotFound {
+		c.JSON(404, gin.H{"error": "Group not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting leeches: %v", err)
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
-	log.Printf("Returning %d words", len(words))
-	c.JSON(200, gin.H{
-		"items": words,
-		"pagination": gin.H{
-			"current_page":   1,
-			"total_pages":    1,
-			"total_items":    len(words),
-			"items_per_page": 100,
-		},
-	})
+	c.JSON(200, response)
 }
 
 func GetWord(c *gin.Context) {
@@ -76,33 +76,119 @@ func GetWord(c *gin.Context) {
 
 	log.Printf("Getting word with ID: %d", id)
 
-	var word struct {
-		Japanese string
-		Romaji   string
-		English  string
+	word, err := services.NewWordService().GetWord(learnerID(c), id)
+	if err == services.ErrWordNotFound {
+		log.Printf("Word not found with ID: %d", id)
+		c.JSON(404, gin.H{"error": "Word not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting word %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
 	}
 
-	err = database.DB.QueryRow(
-		"SELECT japanese, romaji, english FROM words WHERE id = ?", 
-		id,
-	).Scan(&word.Japanese, &word.Romaji, &word.English)
+	log.Printf("Found word %d: %s (%s) - %s", id, word.Japanese, word.Romaji, word.English)
+	c.JSON(200, word)
+}
+
+type wordRequest struct {
+	Japanese string            `json:"japanese"`
+	Romaji   string            `json:"romaji"`
+	English  string            `json:"english"`
+	Parts    []models.WordPart `json:"parts"`
+}
+
+func CreateWord(c *gin.Context) {
+	var req wordRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
 
+	word, err := services.NewWordService().CreateWord(models.Word{
+		Japanese: req.Japanese,
+		Romaji:   req.Romaji,
+		English:  req.English,
+		Parts:    req.Parts,
+	})
+	if respondValidationError(c, err) {
+		return
+	}
+	if err == services.ErrDuplicateWord {
+		c.JSON(409, gin.H{"error": "A word with this japanese and romaji already exists"})
+		return
+	}
 	if err != nil {
-		log.Printf("Word not found with ID: %d", id)
+		log.Printf("Error creating word: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Created word %d: %s (%s) - %s", word.ID, word.Japanese, word.Romaji, word.English)
+	c.JSON(201, word)
+}
+
+func UpdateWord(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var req wordRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	word, err := services.NewWordService().UpdateWord(id, models.Word{
+		Japanese: req.Japanese,
+		Romaji:   req.Romaji,
+		English:  req.English,
+		Parts:    req.Parts,
+	})
+	if respondValidationError(c, err) {
+		return
+	}
+	switch err {
+	case nil:
+	case services.ErrWordNotFound:
 		c.JSON(404, gin.H{"error": "Word not found"})
 		return
+	case services.ErrDuplicateWord:
+		c.JSON(409, gin.H{"error": "A word with this japanese and romaji already exists"})
+		return
+	default:
+		log.Printf("Error updating word %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
 	}
 
-	log.Printf("Found word %d: %s (%s) - %s", id, word.Japanese, word.Romaji, word.English)
+	c.JSON(200, word)
+}
+
+func DeleteWord(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	err = services.NewWordService().DeleteWord(id)
+	if err == services.ErrWordNotFound {
+		c.JSON(404, gin.H{"error": "Word not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting word %d: %v", id, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("Deleted word %d", id)
 	c.JSON(200, gin.H{
-		"id":       id,
-		"japanese": word.Japanese,
-		"romaji":   word.Romaji,
-		"english":  word.English,
-		"stats": gin.H{
-			"correct_count": 0,
-			"wrong_count":   0,
-		},
-		"groups": []interface{}{},
+		"success": true,
+		"message": "Word has been deleted",
 	})
-} 
\ No newline at end of file
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+	"github.com/gin-gonic/gin"
+	"github.com/mohawa/lang-portal/backend_go/internal/launchtoken"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
+)
+
+// XAPIVersionHeader tells xAPI clients which version of the spec the
+// learning record store answers with
+func XAPIVersionHeader(c *gin.Context) {
+	c.Header("X-Experience-API-Version", models.XAPIVersion)
+	c.Next()
+}
+
+// respondStatementError writes the response for an error storing statements.
+// Invalid statements are a 400, as the xAPI spec asks, rather than a 422.
+func respondStatementError(c *gin.Context, err error) {
+	if respondLaunchTokenError(c, err) {
+		return
+	}
+	var validationErr *services.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		c.JSON(400, gin.H{"error": validationErr.Error(), "field": validationErr.Field})
+	case err == services.ErrStatementConflict:
+		c.JSON(409, gin.H{"error": err.Error()})
+	case err == services.ErrStudySessionNotFound:
+		c.JSON(404, gin.H{"error": "Study session not found"})
+	case err == services.ErrStudySessionEnded:
+		c.JSON(409, gin.H{"error": "Study session has ended"})
+	case err == services.ErrWordNotFound:
+		c.JSON(404, gin.H{"error": "Word not found"})
+	default:
+		log.Printf("Error storing xAPI statements: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+	}
+}
+
+// launchClaims reads the launch token an xAPI request may carry; statements
+// that change a study session need one for it. Requests without one must
+// come from a signed-in learner.
+func launchClaims(c *gin.Context) (*launchtoken.Claims, bool) {
+	token := bearerToken(c)
+	if !isLaunchToken(token) {
+		if learnerID(c) == 0 {
+			respondSignInRequired(c)
+			return nil, false
+		}
+		return nil, true
+	}
+	claims, err := services.ParseLaunchToken(token)
+	if err != nil {
+		respondLaunchTokenError(c, err)
+		return nil, false
+	}
+	return claims, true
+}
+
+// PostXAPIStatements stores one statement or an array of them and returns
+// their ids
+func PostXAPIStatements(c *gin.Context) {
+	launch, ok := launchClaims(c)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var statements []json.RawMessage
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &statements)
+	} else {
+		var statement json.RawMessage
+		err = json.Unmarshal(trimmed, &statement)
+		statements = []json.RawMessage{statement}
+	}
+	if err != nil || len(statements) == 0 {
+		c.JSON(400, gin.H{"error": "Send a statement or a non-empty array of statements"})
+		return
+	}
+
+	ids, err := services.NewXAPIService().StoreStatements(statements, learnerID(c), launch)
+	if err != nil {
+		respondStatementError(c, err)
+		return
+	}
+	c.JSON(200, ids)
+}
+
+// PutXAPIStatement stores a single statement under the id given by the
+// statementId query parameter
+func PutXAPIStatement(c *gin.Context) {
+	id := c.Query("statementId")
+	if id == "" {
+		c.JSON(400, gin.H{"error": "statementId is required"})
+		return
+	}
+	launch, ok := launchClaims(c)
+	if !ok {
+		return
+	}
+
+	var statement map[string]interface{}
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&statement); err != nil || statement == nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if existing, ok := statement["id"]; ok && existing != id {
+		c.JSON(400, gin.H{"error": "statement id does not match statementId"})
+		return
+	}
+	statement["id"] = id
+	data, err := json.Marshal(statement)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if _, err := services.NewXAPIService().StoreStatements([]json.RawMessage{data}, learnerID(c), launch); err != nil {
+		respondStatementError(c, err)
+		return
+	}
+	c.Status(204)
+}
+
+// GetXAPIStatements returns a single statement by statementId or
+// voidedStatementId, or a page of statements matching the query filters
+func GetXAPIStatements(c *gin.Context) {
+	service := services.NewXAPIService()
+
+	statementID, voidedID := c.Query("statementId"), c.Query("voidedStatementId")
+	if statementID != "" || voidedID != "" {
+		if statementID != "" && voidedID != "" {
+			c.JSON(400, gin.H{"error": "Use only one of statementId and voidedStatementId"})
+			return
+		}
+		statement, err := service.GetStatement(learnerID(c), statementID+voidedID, voidedID != "")
+		if err == services.ErrStatementNotFound {
+			c.JSON(404, gin.H{"error": "Statement not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error fetching xAPI statement %s: %v", statementID+voidedID, err)
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.Data(200, "application/json; charset=utf-8", statement)
+		return
+	}
+
+	query := services.StatementQuery{
+		Verb:         c.Query("verb"),
+		Activity:     c.Query("activity"),
+		Registration: c.Query("registration"),
+		Ascending:    getBoolQuery(c, "ascending"),
+		Page:         getPage(c),
+	}
+	if value := c.Query("agent"); value != "" {
+		agent, err := services.ParseAgent(value)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error(), "field": "agent"})
+			return
+		}
+		query.Agent = agent
+	}
+	for name, target := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := c.Query(name); value != "" {
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "Invalid " + name + " format"})
+				return
+			}
+			*target = t
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			c.JSON(400, gin.H{"error": "Invalid limit format"})
+			return
+		}
+		query.Limit = limit
+	}
+
+	statements, more, err := service.GetStatements(learnerID(c), query)
+	if err != nil {
+		log.Printf("Error listing xAPI statements: %v", err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := models.XAPIStatementResult{Statements: statements}
+	if more {
+		next := url.Values{}
+		for name, values := range c.Request.URL.Query() {
+			next[name] = values
+		}
+		next.Set("page", strconv.Itoa(query.Page+1))
+		result.More = c.Request.URL.Path + "?" + next.Encode()
+	}
+	c.JSON(200, result)
+}
@@ -0,0 +1,275 @@
+// Package importer reads vocabulary from spreadsheet exports and Anki decks
+// into records of named fields, leaving it to the caller to map the fields
+// onto words.
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Supported formats
+const (
+	FormatCSV  = "csv"
+	FormatTSV  = "tsv"
+	FormatApkg = "apkg"
+)
+
+// ErrUnsupportedDeck is returned for Anki packages that only contain the
+// compressed collection format of recent Anki versions
+var ErrUnsupportedDeck = errors.New("deck uses the compressed collection format; export it with \"Support older Anki versions\" enabled")
+
+// Record is one row or note of an import, with its fields keyed by column
+// header, column number (1-based, for files without a header) or Anki field
+// name. Row is the 1-based row or note number, for reporting errors.
+type Record struct {
+	Row    int
+	Fields map[string]string
+}
+
+// FormatFromFilename guesses the format from a file's extension
+func FormatFromFilename(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return FormatCSV
+	case ".tsv", ".tab", ".txt":
+		return FormatTSV
+	case ".apkg":
+		return FormatApkg
+	}
+	return ""
+}
+
+// Read parses data in the given format. hasHeader only applies to CSV and TSV.
+func Read(data []byte, format string, hasHeader bool) ([]Record, error) {
+	switch format {
+	case FormatCSV:
+		return ReadDelimited(bytes.NewReader(data), ',', hasHeader)
+	case FormatTSV:
+		return ReadDelimited(bytes.NewReader(data), '\t', hasHeader)
+	case FormatApkg:
+		return ReadApkg(data)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// ReadDelimited reads CSV or TSV. With a header, fields are keyed by the
+// trimmed header names; without one by their 1-based column number. Blank
+// lines are skipped.
+func ReadDelimited(r io.Reader, comma rune, hasHeader bool) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	// Spreadsheet exports are not always strict about quoting
+	reader.LazyQuotes = true
+
+	var header []string
+	records := make([]Record, 0)
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(fields) == 0 || (len(fields) == 1 && strings.TrimSpace(fields[0]) == "") {
+			continue
+		}
+
+		if hasHeader && header == nil {
+			header = make([]string, len(fields))
+			for i, name := range fields {
+				header[i] = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+			}
+			continue
+		}
+
+		record := Record{Row: line, Fields: map[string]string{}}
+		for i, value := range fields {
+			key := strconv.Itoa(i + 1)
+			if header != nil {
+				if i >= len(header) || header[i] == "" {
+					continue
+				}
+				key = header[i]
+			}
+			record.Fields[key] = strings.TrimSpace(value)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+var (
+	htmlTag   = regexp.MustCompile(`(?i)<br\s*/?>|<div>|<[^>]*>`)
+	soundTag  = regexp.MustCompile(`\[sound:[^\]]*\]`)
+	lineBreak = regexp.MustCompile(`(?i)^<br\s*/?>$|^<div>$`)
+)
+
+// cleanField turns an Anki field into plain text, dropping markup and sound
+// references and turning line breaks into "; "
+func cleanField(value string) string {
+	value = soundTag.ReplaceAllString(value, "")
+	value = htmlTag.ReplaceAllStringFunc(value, func(tag string) string {
+		if lineBreak.MatchString(tag) {
+			return "; "
+		}
+		return ""
+	})
+	value = strings.ReplaceAll(html.UnescapeString(value), "\u00a0", " ")
+	return strings.Trim(strings.TrimSpace(value), "; ")
+}
+
+// ReadApkg reads the notes of an Anki package, keying each note's fields by
+// the names its note type gives them
+func ReadApkg(data []byte) ([]Record, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not an Anki package: %v", err)
+	}
+
+	// Prefer the newer schema when a package carries both
+	var collection *zip.File
+	compressed := false
+	for _, file := range archive.File {
+		switch file.Name {
+		case "collection.anki21":
+			collection = file
+		case "collection.anki2":
+			if collection == nil {
+				collection = file
+			}
+		case "collection.anki21b":
+			compressed = true
+		}
+	}
+	if collection == nil {
+		if compressed {
+			return nil, ErrUnsupportedDeck
+		}
+		return nil, fmt.Errorf("not an Anki package: no collection found")
+	}
+
+	// SQLite needs the collection on disk
+	tmp, err := os.CreateTemp("", "import-*.anki2")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	src, err := collection.Open()
+	if err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	_, err = io.Copy(tmp, src)
+	src.Close()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+tmp.Name()+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	fieldNames, err := noteTypeFields(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT mid, flds FROM notes ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notes: %v", err)
+	}
+	defer rows.Close()
+
+	records := make([]Record, 0)
+	for rows.Next() {
+		var noteType int64
+		var fields string
+		if err := rows.Scan(&noteType, &fields); err != nil {
+			return nil, err
+		}
+		record := Record{Row: len(records) + 1, Fields: map[string]string{}}
+		names := fieldNames[noteType]
+		for i, value := range strings.Split(fields, "\x1f") {
+			key := strconv.Itoa(i + 1)
+			if i < len(names) {
+				key = names[i]
+			}
+			record.Fields[key] = cleanField(value)
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// noteTypeFields returns the field names of every note type in a collection,
+// in field order. Older collections keep note types as JSON in col.models;
+// newer ones have a fields table instead.
+func noteTypeFields(db *sql.DB) (map[int64][]string, error) {
+	fields := map[int64][]string{}
+
+	var raw string
+	if err := db.QueryRow("SELECT models FROM col").Scan(&raw); err != nil {
+		return nil, fmt.Errorf("failed to read note types: %v", err)
+	}
+	if strings.TrimSpace(raw) == "" || raw == "{}" {
+		rows, err := db.Query("SELECT ntid, name FROM fields ORDER BY ntid, ord")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read note types: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var noteTypeID int64
+			var name string
+			if err := rows.Scan(&noteTypeID, &name); err != nil {
+				return nil, err
+			}
+			fields[noteTypeID] = append(fields[noteTypeID], name)
+		}
+		return fields, rows.Err()
+	}
+
+	var noteTypes map[string]struct {
+		Fields []struct {
+			Name string `json:"name"`
+			Ord  int    `json:"ord"`
+		} `json:"flds"`
+	}
+	if err := json.Unmarshal([]byte(raw), &noteTypes); err != nil {
+		return nil, fmt.Errorf("failed to parse note types: %v", err)
+	}
+
+	for id, noteType := range noteTypes {
+		noteTypeID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		sort.Slice(noteType.Fields, func(i, j int) bool { return noteType.Fields[i].Ord < noteType.Fields[j].Ord })
+		for _, field := range noteType.Fields {
+			fields[noteTypeID] = append(fields[noteTypeID], field.Name)
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,190 @@
+package kana
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// romajiKana maps syllables in any supported romanisation system, plus the
+// common IME spellings for small kana, back to hiragana
+var romajiKana = buildRomajiKana()
+
+func buildRomajiKana() map[string]string {
+	table := map[string]string{}
+	for kana, romaji := range hiraganaRomaji {
+		// Small kana and the archaic or rare variants would otherwise shadow
+		// the usual kana for the same spelling (o, ji, zu, ...)
+		if utf8.RuneCountInString(kana) == 1 && strings.ContainsAny(kana, "ぁぃぅぇぉゃゅょゎゐゑを") {
+			continue
+		}
+		if strings.ContainsAny(kana, "ぢづ") {
+			continue
+		}
+		table[romaji] = kana
+	}
+	for kana, romaji := range kunreiRomaji {
+		if !strings.ContainsAny(kana, "ぢづ") {
+			table[romaji] = kana
+		}
+	}
+	for kana, romaji := range nihonShikiRomaji {
+		if romaji != "wi" && romaji != "we" {
+			table[romaji] = kana
+		}
+	}
+
+	for romaji, kana := range map[string]string{
+		"jya": "じゃ", "jyu": "じゅ", "jyo": "じょ",
+		"xa": "ぁ", "xi": "ぃ", "xu": "ぅ", "xe": "ぇ", "xo": "ぉ",
+		"la": "ぁ", "li": "ぃ", "lu": "ぅ", "le": "ぇ", "lo": "ぉ",
+		"xya": "ゃ", "xyu": "ゅ", "xyo": "ょ", "lya": "ゃ", "lyu": "ゅ", "lyo": "ょ",
+		"xtu": "っ", "ltu": "っ", "xtsu": "っ", "ltsu": "っ", "xwa": "ゎ",
+		"-": "ー",
+	} {
+		table[romaji] = kana
+	}
+	return table
+}
+
+// romajiSyllables holds the syllables of Hepburn, Kunrei-shiki and
+// Nihon-shiki, leaving out the IME spellings of small kana (la, xtu, ...)
+var romajiSyllables = buildRomajiSyllables()
+
+func buildRomajiSyllables() map[string]bool {
+	syllables := map[string]bool{}
+	for _, table := range []map[string]string{hiraganaRomaji, kunreiRomaji, nihonShikiRomaji} {
+		for _, romaji := range table {
+			syllables[romaji] = true
+		}
+	}
+	return syllables
+}
+
+// macronReplacer spells out long vowels written with macrons or circumflexes
+var macronReplacer = strings.NewReplacer(
+	"ā", "aa", "ī", "ii", "ū", "uu", "ē", "ee", "ō", "ou",
+	"â", "aa", "î", "ii", "û", "uu", "ê", "ee", "ô", "ou",
+)
+
+// isSyllabicM reports whether the m at s[i] is ん, which traditional Hepburn
+// writes after a vowel and before b, m and p
+func isSyllabicM(s string, i int) bool {
+	return s[i] == 'm' && i > 0 && isVowel(s[i-1]) && i+1 < len(s) && strings.IndexByte("bmp", s[i+1]) >= 0
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("aiueo", c) >= 0
+}
+
+func isConsonant(c byte) bool {
+	return c >= 'a' && c <= 'z' && !isVowel(c)
+}
+
+// FromRomaji converts romaji written in Hepburn (modern or traditional),
+// Kunrei-shiki, Nihon-shiki or IME style into hiragana. Where the systems
+// disagree the Kunrei reading wins, so "ti" is ち rather than てぃ. Text that is not romaji, including
+// kana and kanji, is passed through unchanged.
+func FromRomaji(s string) string {
+	s = macronReplacer.Replace(strings.ToLower(NormalizeWidth(s)))
+
+	var out strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		// Traditional Hepburn writes ん as m before b, m and p (shimbun)
+		if isSyllabicM(s, i) {
+			out.WriteString("ん")
+			i++
+			continue
+		}
+
+		// A doubled consonant, or t before ch, is a small tsu
+		if isConsonant(c) && c != 'n' && i+1 < len(s) &&
+			(s[i+1] == c || (c == 't' && strings.HasPrefix(s[i+1:], "ch"))) {
+			out.WriteString("っ")
+			i++
+			continue
+		}
+
+		// n is ん unless it starts a syllable; "n'" and "nn" spell it explicitly
+		if c == 'n' && (i+1 == len(s) || (!isVowel(s[i+1]) && s[i+1] != 'y')) {
+			out.WriteString("ん")
+			i++
+			if i < len(s) && (s[i] == '\'' ||
+				(s[i] == 'n' && (i+1 == len(s) || (!isVowel(s[i+1]) && s[i+1] != 'y')))) {
+				i++
+			}
+			continue
+		}
+
+		matched := false
+		for size := 4; size >= 1; size-- {
+			if i+size > len(s) {
+				continue
+			}
+			if kana, ok := romajiKana[s[i:i+size]]; ok {
+				out.WriteString(kana)
+				i += size
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			out.WriteRune(r)
+			i += size
+		}
+	}
+	return out.String()
+}
+
+// IsRomaji reports whether the letters of s spell syllables of Hepburn,
+// Kunrei-shiki or Nihon-shiki romaji, so that "taberu" and "shimbun" are
+// romaji and "hello" is not. Spaces, punctuation and digits may separate
+// the syllables.
+func IsRomaji(s string) bool {
+	s = macronReplacer.Replace(strings.ToLower(NormalizeWidth(s)))
+	letters := false
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if unicode.IsLetter(r) {
+				return false
+			}
+			i += size
+			continue
+		}
+		if c < 'a' || c > 'z' {
+			i++
+			continue
+		}
+		letters = true
+
+		// A doubled consonant or t before ch is a small tsu, and n or m
+		// closing a syllable is ん; each is followed by more romaji
+		if isSyllabicM(s, i) || (isConsonant(c) && c != 'n' && i+1 < len(s) &&
+			(s[i+1] == c || (c == 't' && strings.HasPrefix(s[i+1:], "ch")))) {
+			i++
+			continue
+		}
+		if c == 'n' && (i+1 == len(s) || (!isVowel(s[i+1]) && s[i+1] != 'y')) {
+			i++
+			continue
+		}
+
+		matched := false
+		for size := 3; size >= 1; size-- {
+			if i+size <= len(s) && romajiSyllables[s[i:i+size]] {
+				i += size
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return letters
+}
@@ -0,0 +1,267 @@
+// Package kana converts between Japanese kana and romaji.
+package kana
+
+import (
+	"strings"
+	"unicode"
+)
+
+// hiraganaRomaji maps hiragana (and two-character yōon combinations) to
+// Hepburn romaji
+var hiraganaRomaji = map[string]string{
+	"あ": "a", "い": "i", "う": "u", "え": "e", "お": "o",
+	"か": "ka", "き": "ki", "く": "ku", "け": "ke", "こ": "ko",
+	"さ": "sa", "し": "shi", "す": "su", "せ": "se", "そ": "so",
+	"た": "ta", "ち": "chi", "つ": "tsu", "て": "te", "と": "to",
+	"な": "na", "に": "ni", "ぬ": "nu", "ね": "ne", "の": "no",
+	"は": "ha", "ひ": "hi", "ふ": "fu", "へ": "he", "ほ": "ho",
+	"ま": "ma", "み": "mi", "む": "mu", "め": "me", "も": "mo",
+	"や": "ya", "ゆ": "yu", "よ": "yo",
+	"ら": "ra", "り": "ri", "る": "ru", "れ": "re", "ろ": "ro",
+	"わ": "wa", "ゐ": "i", "ゑ": "e", "を": "o", "ん": "n",
+	"が": "ga", "ぎ": "gi", "ぐ": "gu", "げ": "ge", "ご": "go",
+	"ざ": "za", "じ": "ji", "ず": "zu", "ぜ": "ze", "ぞ": "zo",
+	"だ": "da", "ぢ": "ji", "づ": "zu", "で": "de", "ど": "do",
+	"ば": "ba", "び": "bi", "ぶ": "bu", "べ": "be", "ぼ": "bo",
+	"ぱ": "pa", "ぴ": "pi", "ぷ": "pu", "ぺ": "pe", "ぽ": "po",
+	"ゔ": "vu",
+	"ぁ": "a", "ぃ": "i", "ぅ": "u", "ぇ": "e", "ぉ": "o",
+	"ゃ": "ya", "ゅ": "yu", "ょ": "yo", "ゎ": "wa",
+
+	"きゃ": "kya", "きゅ": "kyu", "きょ": "kyo",
+	"しゃ": "sha", "しゅ": "shu", "しょ": "sho", "しぇ": "she",
+	"ちゃ": "cha", "ちゅ": "chu", "ちょ": "cho", "ちぇ": "che",
+	"にゃ": "nya", "にゅ": "nyu", "にょ": "nyo",
+	"ひゃ": "hya", "ひゅ": "hyu", "ひょ": "hyo",
+	"みゃ": "mya", "みゅ": "myu", "みょ": "myo",
+	"りゃ": "rya", "りゅ": "ryu", "りょ": "ryo",
+	"ぎゃ": "gya", "ぎゅ": "gyu", "ぎょ": "gyo",
+	"じゃ": "ja", "じゅ": "ju", "じょ": "jo", "じぇ": "je",
+	"ぢゃ": "ja", "ぢゅ": "ju", "ぢょ": "jo",
+	"びゃ": "bya", "びゅ": "byu", "びょ": "byo",
+	"ぴゃ": "pya", "ぴゅ": "pyu", "ぴょ": "pyo",
+	"ふぁ": "fa", "ふぃ": "fi", "ふぇ": "fe", "ふぉ": "fo",
+	"てぃ": "ti", "でぃ": "di", "とぅ": "tu", "どぅ": "du",
+	"うぃ": "wi", "うぇ": "we", "うぉ": "wo",
+	"ゔぁ": "va", "ゔぃ": "vi", "ゔぇ": "ve", "ゔぉ": "vo",
+}
+
+// IsHiragana reports whether r is a hiragana character
+func IsHiragana(r rune) bool {
+	return r >= 'ぁ' && r <= 'ゖ'
+}
+
+// IsKatakana reports whether r is a katakana character or the prolonged sound mark
+func IsKatakana(r rune) bool {
+	return (r >= 'ァ' && r <= 'ヺ') || r == 'ー'
+}
+
+// IsKana reports whether s consists only of kana, spaces and the
+// prolonged sound mark, i.e. its reading can be derived without a dictionary
+func IsKana(s string) bool {
+	if strings.TrimSpace(s) == "" {
+		return false
+	}
+	for _, r := range s {
+		if !IsHiragana(r) && !IsKatakana(r) && !unicode.IsSpace(r) && r != '・' {
+			return false
+		}
+	}
+	return true
+}
+
+// System is a romanisation system
+type System int
+
+const (
+	Hepburn System = iota
+	Kunrei
+	NihonShiki
+)
+
+// kunreiRomaji holds the spellings where Kunrei-shiki differs from Hepburn
+var kunreiRomaji = map[string]string{
+	"し": "si", "ち": "ti", "つ": "tu", "ふ": "hu", "じ": "zi", "ぢ": "zi", "づ": "zu",
+	"しゃ": "sya", "しゅ": "syu", "しょ": "syo",
+	"ちゃ": "tya", "ちゅ": "tyu", "ちょ": "tyo",
+	"じゃ": "zya", "じゅ": "zyu", "じょ": "zyo",
+	"ぢゃ": "zya", "ぢゅ": "zyu", "ぢょ": "zyo",
+}
+
+// nihonShikiRomaji holds the spellings where Nihon-shiki differs from
+// Kunrei-shiki, which keeps the historical d and w rows
+var nihonShikiRomaji = map[string]string{
+	"ぢ": "di", "づ": "du", "ぢゃ": "dya", "ぢゅ": "dyu", "ぢょ": "dyo",
+	"ゐ": "wi", "ゑ": "we", "を": "wo",
+}
+
+// romajiFor returns the spelling of a kana (or yōon pair) in the given system
+func romajiFor(kana string, system System) (string, bool) {
+	if system == NihonShiki {
+		if romaji, ok := nihonShikiRomaji[kana]; ok {
+			return romaji, true
+		}
+	}
+	if system != Hepburn {
+		if romaji, ok := kunreiRomaji[kana]; ok {
+			return romaji, true
+		}
+	}
+	romaji, ok := hiraganaRomaji[kana]
+	return romaji, ok
+}
+
+// ToRomaji converts kana to Hepburn romaji. Characters that are not kana are
+// passed through unchanged.
+func ToRomaji(s string) string {
+	return ToRomajiSystem(s, Hepburn)
+}
+
+// ToRomajiSystem converts kana to romaji in the given system. Long vowels are
+// spelled out (おう as "ou") rather than written with macrons.
+func ToRomajiSystem(s string, system System) string {
+	runes := []rune(ToHiragana(NormalizeWidth(s)))
+	var out strings.Builder
+	geminate := false
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if r == 'っ' {
+			geminate = true
+			continue
+		}
+
+		if r == 'ー' {
+			// Prolonged sound mark repeats the previous vowel
+			if last := lastVowel(out.String()); last != 0 {
+				out.WriteRune(last)
+			}
+			continue
+		}
+
+		romaji := ""
+		if i+1 < len(runes) {
+			if pair, ok := romajiFor(string(runes[i:i+2]), system); ok {
+				romaji = pair
+				i++
+			}
+		}
+		if romaji == "" {
+			single, ok := romajiFor(string(r), system)
+			if !ok {
+				if geminate {
+					out.WriteString("tsu")
+					geminate = false
+				}
+				out.WriteRune(r)
+				continue
+			}
+			romaji = single
+		}
+
+		if geminate {
+			if system == Hepburn && strings.HasPrefix(romaji, "ch") {
+				out.WriteByte('t')
+			} else {
+				out.WriteByte(romaji[0])
+			}
+			geminate = false
+		}
+
+		// ん is written n' before vowels and y to keep syllables unambiguous
+		if r == 'ん' && i+1 < len(runes) {
+			if next, ok := hiraganaRomaji[string(runes[i+1])]; ok && strings.ContainsRune("aiueoy", rune(next[0])) {
+				romaji = "n'"
+			}
+		}
+
+		out.WriteString(romaji)
+	}
+
+	if geminate {
+		out.WriteString("tsu")
+	}
+	return out.String()
+}
+
+func lastVowel(s string) rune {
+	for i := len(s) - 1; i >= 0; i-- {
+		if strings.IndexByte("aiueo", s[i]) >= 0 {
+			return rune(s[i])
+		}
+	}
+	return 0
+}
+
+// longVowelReplacer writes each long vowel one way whichever notation it was
+// spelled in, so that "ou" and "oo" both become "ō". Short vowels are left
+// alone: "yuki" and "yūki" are different words.
+var longVowelReplacer = strings.NewReplacer(
+	"ou", "ō", "oo", "ō", "uu", "ū", "aa", "ā", "ii", "ī", "ee", "ē",
+)
+
+// separatorReplacer reads hyphens as word breaks ("o-genki") and curly
+// apostrophes as straight ones
+var separatorReplacer = strings.NewReplacer("-", " ", "’", "'")
+
+// ReadingKey reduces a reading written in kana or in any romanisation system
+// to a comparison key, so that "ohayō", "ohayou", "ohayoo", "おはよう" and
+// "オハヨウ" all share the same key. Romaji is read back into kana first,
+// which folds Kunrei and Nihon-shiki spellings such as "si" and "tu" into
+// Hepburn and makes spaces and apostrophes optional where they change
+// nothing; "kin'en" (きんえん) and "kinen" (きねん) keep different keys.
+func ReadingKey(s string) string {
+	s = separatorReplacer.Replace(strings.ToLower(NormalizeWidth(strings.TrimSpace(s))))
+	kana := strings.Join(strings.Fields(FromRomaji(ToRomaji(s))), "")
+	return longVowelReplacer.Replace(ToRomaji(kana))
+}
+
+// SameReading reports whether a and b are the same reading, whichever script
+// or romanisation system each is written in
+func SameReading(a, b string) bool {
+	key := ReadingKey(a)
+	return key != "" && key == ReadingKey(b)
+}
+
+// particleKana maps the kana read differently as particles to their reading
+var particleKana = map[rune]rune{'は': 'わ', 'へ': 'え'}
+
+// particleReadings returns the ways kana may be read: は and へ ending a word
+// may be the particles wa and e, as in こんにちは (konnichiwa)
+func particleReadings(s string) []string {
+	runes := []rune(ToHiragana(s))
+	readings := [][]rune{runes}
+	for i, r := range runes {
+		particle, ok := particleKana[r]
+		if !ok || (i+1 < len(runes) && !unicode.IsSpace(runes[i+1])) {
+			continue
+		}
+		for _, reading := range readings {
+			variant := append([]rune(nil), reading...)
+			variant[i] = particle
+			readings = append(readings, variant)
+		}
+	}
+
+	result := make([]string, len(readings))
+	for i, reading := range readings {
+		result[i] = string(reading)
+	}
+	return result
+}
+
+// ReadingMatches reports whether romaji is a reading of the kana in japanese,
+// allowing for particles. It always returns true when japanese contains
+// kanji, since the reading cannot be derived without a dictionary.
+func ReadingMatches(japanese, romaji string) bool {
+	if !IsKana(japanese) {
+		return true
+	}
+	for _, reading := range particleReadings(japanese) {
+		if SameReading(reading, romaji) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package kana
+
+import (
+	"strings"
+)
+
+// ToHiragana folds katakana into hiragana, leaving other characters as-is
+func ToHiragana(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ァ' && r <= 'ヶ' {
+			return r - 0x60
+		}
+		return r
+	}, s)
+}
+
+// ToKatakana folds hiragana into katakana, leaving other characters as-is
+func ToKatakana(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ぁ' && r <= 'ゖ' {
+			return r + 0x60
+		}
+		return r
+	}, s)
+}
+
+// Half-width katakana and punctuation (U+FF61 to U+FF9D) and their
+// full-width forms, in the same order
+var (
+	halfWidthKana = []rune("｡｢｣､･ｦｧｨｩｪｫｬｭｮｯｰｱｲｳｴｵｶｷｸｹｺｻｼｽｾｿﾀﾁﾂﾃﾄﾅﾆﾇﾈﾉﾊﾋﾌﾍﾎﾏﾐﾑﾒﾓﾔﾕﾖﾗﾘﾙﾚﾛﾜﾝ")
+	fullWidthKana = []rune("。「」、・ヲァィゥェォャュョッーアイウエオカキクケコサシスセソタチツテトナニヌネノハヒフヘホマミムメモヤユヨラリルレロワン")
+)
+
+const (
+	halfWidthDakuten    = 'ﾞ'
+	halfWidthHandakuten = 'ﾟ'
+)
+
+// NormalizeWidth converts full-width latin letters, digits and punctuation
+// to ASCII and half-width katakana to full-width, combining a following
+// half-width (han)dakuten into the preceding kana (ｶﾞ becomes ガ)
+func NormalizeWidth(s string) string {
+	var out strings.Builder
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r >= '！' && r <= '～':
+			out.WriteRune(r - 0xFEE0)
+		case r == '　':
+			out.WriteRune(' ')
+		case r >= '｡' && r <= 'ﾝ':
+			full := fullWidthKana[r-'｡']
+			if i+1 < len(runes) {
+				if voiced, ok := addDakuten(full, runes[i+1]); ok {
+					full = voiced
+					i++
+				}
+			}
+			out.WriteRune(full)
+		case r == halfWidthDakuten:
+			out.WriteRune('゛')
+		case r == halfWidthHandakuten:
+			out.WriteRune('゜')
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// addDakuten combines a full-width katakana with a following half-width
+// voicing mark, reporting false when the pair does not combine
+func addDakuten(r, mark rune) (rune, bool) {
+	switch mark {
+	case halfWidthDakuten:
+		switch {
+		case r == 'ウ':
+			return 'ヴ', true
+		case (r >= 'カ' && r <= 'チ' && (r-'カ')%2 == 0) || r == 'ツ' || r == 'テ' || r == 'ト':
+			return r + 1, true
+		case r >= 'ハ' && r <= 'ホ' && (r-'ハ')%3 == 0:
+			return r + 1, true
+		}
+	case halfWidthHandakuten:
+		if r >= 'ハ' && r <= 'ホ' && (r-'ハ')%3 == 0 {
+			return r + 2, true
+		}
+	}
+	return r, false
+}
@@ -0,0 +1,96 @@
+// Package launchtoken signs and verifies the tokens the portal hands to a
+// study activity app when launching it. Tokens are JWTs signed with
+// HMAC-SHA256 and scoped to a single study session.
+package launchtoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed = errors.New("launch token is malformed")
+	ErrSignature = errors.New("launch token signature is invalid")
+	ErrExpired   = errors.New("launch token has expired")
+)
+
+// header is the only JWT header tokens are signed with. Verify rejects any
+// other algorithm, so a token cannot pick a weaker one for itself.
+const header = `{"alg":"HS256","typ":"JWT"}`
+
+// Claims are the session a token was issued for, the learner studying in it
+// and when the token is valid
+type Claims struct {
+	StudySessionID  int   `json:"study_session_id"`
+	UserID          int   `json:"user_id"`
+	GroupID         int   `json:"group_id"`
+	StudyActivityID int   `json:"study_activity_id"`
+	IssuedAt        int64 `json:"iat"`
+	ExpiresAt       int64 `json:"exp"`
+}
+
+// Expires returns the time the token stops being valid
+func (c *Claims) Expires() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func sign(secret []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+// Sign returns a token carrying the claims
+func Sign(secret []byte, claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := encode([]byte(header)) + "." + encode(payload)
+	return signed + "." + encode(sign(secret, signed)), nil
+}
+
+// Verify checks a token's signature and expiry at now and returns its claims
+func Verify(secret []byte, token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var head struct {
+		Alg string `json:"alg"`
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(data, &head) != nil {
+		return nil, ErrMalformed
+	}
+	if head.Alg != "HS256" {
+		return nil, ErrSignature
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !hmac.Equal(signature, sign(secret, parts[0]+"."+parts[1])) {
+		return nil, ErrSignature
+	}
+
+	var claims Claims
+	data, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(data, &claims) != nil || claims.StudySessionID == 0 {
+		return nil, ErrMalformed
+	}
+	if !now.Before(claims.Expires()) {
+		return nil, ErrExpired
+	}
+	return &claims, nil
+}
@@ -0,0 +1,30 @@
+package models
+
+// Analytics bucket sizes
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// AnalyticsBucket totals the study done between two dates, inclusive, in the
+// learner's timezone. Accuracy is the percentage of reviews answered correctly.
+type AnalyticsBucket struct {
+	Start         string  `json:"start"`
+	End           string  `json:"end"`
+	Reviews       int     `json:"reviews"`
+	CorrectCount  int     `json:"correct_count"`
+	Accuracy      float64 `json:"accuracy"`
+	NewWords      int     `json:"new_words"`
+	StudySessions int     `json:"study_sessions"`
+	StudySeconds  int     `json:"study_seconds"`
+}
+
+type ReviewAnalytics struct {
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Bucket   string            `json:"bucket"`
+	Timezone string            `json:"timezone"`
+	Items    []AnalyticsBucket `json:"items"`
+	Totals   AnalyticsBucket   `json:"totals"`
+}
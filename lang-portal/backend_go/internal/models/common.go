@@ -17,9 +17,27 @@ type DashboardStats struct {
 	TotalStudySessions int     `json:"total_study_sessions"`
 	TotalActiveGroups  int     `json:"total_active_groups"`
 	StudyStreakDays    int     `json:"study_streak_days"`
+	// Streaks count days on which the daily goal was met, in the learner's timezone
+	CurrentStreakDays int               `json:"current_streak_days"`
+	LongestStreakDays int               `json:"longest_streak_days"`
+	StreakFreezesUsed int               `json:"streak_freezes_used"`
+	StudiedToday      bool              `json:"studied_today"`
+	Today             DailyGoalProgress `json:"today"`
+	AverageResponseMs  float64 `json:"average_response_ms"`
+	GradedReviews      int     `json:"graded_reviews"`
+	EasyRate           float64 `json:"easy_rate"`
 }
 
 type StudyProgress struct {
 	TotalWordsStudied    int `json:"total_words_studied"`
 	TotalAvailableWords int `json:"total_available_words"`
-} 
\ No newline at end of file
+	// Time spent in study sessions, counting active ones up to their last activity
+	TotalStudySeconds     int     `json:"total_study_seconds"`
+	AverageSessionSeconds float64 `json:"average_session_seconds"`
+} 
+// ResetResult counts what a reset deleted
+type ResetResult struct {
+	ReviewsDeleted       int64 `json:"reviews_deleted"`
+	StudySessionsDeleted int64 `json:"study_sessions_deleted"`
+	StatementsDeleted    int64 `json:"statements_deleted"`
+}
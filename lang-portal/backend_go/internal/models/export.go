@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+)
+
+// ExportedWord is a word with everything learned about it so far. The
+// schedule fields are nil for words that have never been scheduled.
+type ExportedWord struct {
+	ID             int        `json:"id"`
+	Japanese       string     `json:"japanese"`
+	Romaji         string     `json:"romaji"`
+	English        string     `json:"english"`
+	Groups         []string   `json:"groups"`
+	CorrectCount   int        `json:"correct_count"`
+	WrongCount     int        `json:"wrong_count"`
+	Mastery        string     `json:"mastery"`
+	Lapses         int        `json:"lapses"`
+	Leech          bool       `json:"leech"`
+	EaseFactor     *float64   `json:"ease_factor"`
+	IntervalDays   *int       `json:"interval_days"`
+	Repetitions    *int       `json:"repetitions"`
+	DueAt          *time.Time `json:"due_at"`
+	LastReviewedAt *time.Time `json:"last_reviewed_at"`
+}
+
+// ExportedReview is one entry of the review log
+type ExportedReview struct {
+	WordID          int       `json:"word_id"`
+	Japanese        string    `json:"japanese"`
+	Romaji          string    `json:"romaji"`
+	English         string    `json:"english"`
+	StudySessionID  int       `json:"study_session_id"`
+	GroupID         int       `json:"group_id"`
+	StudyActivityID *int      `json:"study_activity_id"`
+	Correct         bool      `json:"correct"`
+	Grade           *string   `json:"grade"`
+	Direction       *string   `json:"direction"`
+	Answer          *string   `json:"answer"`
+	ResponseMs      *int      `json:"response_ms"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// WordExport is the vocabulary of one group, or of every word when GroupID
+// is nil
+type WordExport struct {
+	ExportedAt time.Time      `json:"exported_at"`
+	GroupID    *int           `json:"group_id"`
+	GroupName  string         `json:"group_name,omitempty"`
+	Words      []ExportedWord `json:"words"`
+}
+
+// ReviewExport is the review log, oldest review first, optionally limited
+// to the sessions of one group
+type ReviewExport struct {
+	ExportedAt time.Time        `json:"exported_at"`
+	GroupID    *int             `json:"group_id"`
+	Reviews    []ExportedReview `json:"reviews"`
+}
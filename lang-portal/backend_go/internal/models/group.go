@@ -1,15 +1,44 @@
 package models
 
+import (
+	"time"
+)
+
 type Group struct {
 	ID        int    `json:"id"`
 	Name      string `json:"name"`
-	WordCount int    `json:"word_count,omitempty"`
+	ParentID  *int   `json:"parent_id"`
+	WordCount int    `json:"word_count"`
+	// Stats is only filled in by group listings
+	Stats *GroupStats `json:"stats,omitempty"`
+}
+
+// MasteryCounts counts words at each mastery level
+type MasteryCounts struct {
+	New      int `json:"new"`
+	Learning int `json:"learning"`
+	Young    int `json:"young"`
+	Mature   int `json:"mature"`
+	Mastered int `json:"mastered"`
+}
+
+// GroupStats summarises progress through a group's words. RecentAccuracy
+// covers the group's RecentSessions most recent study sessions and is nil
+// until they hold a review; EstimatedDaysToCompletion is nil while no new words are being studied.
+type GroupStats struct {
+	TotalWordCount            int           `json:"total_word_count"`
+	WordsStudied              int           `json:"words_studied"`
+	Mastery                   MasteryCounts `json:"mastery"`
+	RecentAccuracy            *float64      `json:"recent_accuracy"`
+	RecentSessions            int           `json:"recent_sessions"`
+	LastStudiedAt             *time.Time    `json:"last_studied_at"`
+	EstimatedDaysToCompletion *int          `json:"estimated_days_to_completion"`
 }
 
 type GroupResponse struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Stats struct {
-		TotalWordCount int `json:"total_word_count"`
-	} `json:"stats"`
-} 
\ No newline at end of file
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	ParentID  *int       `json:"parent_id"`
+	Stats     GroupStats `json:"stats"`
+	Subgroups []Group    `json:"subgroups"`
+}
@@ -0,0 +1,50 @@
+package models
+
+// Import row statuses. New rows create a word, duplicates match an existing
+// word exactly and conflicts share its japanese and reading but not its
+// english.
+const (
+	ImportNew       = "new"
+	ImportDuplicate = "duplicate"
+	ImportConflict  = "conflict"
+	ImportError     = "error"
+)
+
+// What a committed import did with each row
+const (
+	ImportCreated = "created"
+	ImportLinked  = "linked"
+	ImportUpdated = "updated"
+	ImportSkipped = "skipped"
+)
+
+// ImportRow reports how one row or note of an import was understood and,
+// once committed, what was done with it
+type ImportRow struct {
+	Row      int    `json:"row"`
+	Japanese string `json:"japanese"`
+	Romaji   string `json:"romaji"`
+	English  string `json:"english"`
+	Status   string `json:"status"`
+	Action   string `json:"action,omitempty"`
+	WordID   *int   `json:"word_id"`
+	Message  string `json:"message,omitempty"`
+}
+
+type ImportSummary struct {
+	Total     int `json:"total"`
+	New       int `json:"new"`
+	Duplicate int `json:"duplicate"`
+	Conflict  int `json:"conflict"`
+	Error     int `json:"error"`
+}
+
+// ImportResult is the preview of an import or, when Committed, its outcome
+type ImportResult struct {
+	Format    string        `json:"format"`
+	Committed bool          `json:"committed"`
+	GroupID   *int          `json:"group_id"`
+	GroupName string        `json:"group_name"`
+	Summary   ImportSummary `json:"summary"`
+	Rows      []ImportRow   `json:"rows"`
+}
@@ -0,0 +1,21 @@
+package models
+
+// Settings are the learner's preferences. Timezone is an IANA zone name used
+// to decide which day a review belongs to; goals of 0 are unset.
+type Settings struct {
+	Timezone         string `json:"timezone"`
+	DailyGoalReviews int    `json:"daily_goal_reviews"`
+	DailyGoalMinutes int    `json:"daily_goal_minutes"`
+	StreakFreezes    int    `json:"streak_freezes"`
+}
+
+// DailyGoalProgress is how far today, in the learner's timezone, has got
+// towards the daily goal
+type DailyGoalProgress struct {
+	Date        string  `json:"date"`
+	Reviews     int     `json:"reviews"`
+	Minutes     float64 `json:"minutes"`
+	GoalReviews int     `json:"goal_reviews"`
+	GoalMinutes int     `json:"goal_minutes"`
+	Met         bool    `json:"met"`
+}
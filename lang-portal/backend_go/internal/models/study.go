@@ -2,26 +2,116 @@ package models
 
 import "time"
 
+// Study session states. Active sessions that see no activity for the idle
+// timeout are closed as abandoned.
+const (
+	SessionActive    = "active"
+	SessionCompleted = "completed"
+	SessionAbandoned = "abandoned"
+)
+
 type StudySession struct {
-	ID              int       `json:"id"`
-	GroupID         int       `json:"group_id"`
-	CreatedAt       time.Time `json:"created_at"`
-	StudyActivityID int       `json:"study_activity_id"`
-	ActivityName    string    `json:"activity_name,omitempty"`
-	GroupName       string    `json:"group_name,omitempty"`
-	ReviewItemCount int       `json:"review_items_count,omitempty"`
+	ID              int        `json:"id"`
+	GroupID         int        `json:"group_id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	StudyActivityID int        `json:"study_activity_id"`
+	Status          string     `json:"status,omitempty"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"`
+	ActivityName    string     `json:"activity_name,omitempty"`
+	GroupName       string     `json:"group_name,omitempty"`
+	ReviewItemCount int        `json:"review_items_count,omitempty"`
+	LaunchURL       string     `json:"launch_url,omitempty"`
+	*LaunchToken
+}
+
+// LaunchToken is a signed token letting the app launched for a study session
+// post results into it until ExpiresAt
+type LaunchToken struct {
+	Token     string    `json:"launch_token"`
+	ExpiresAt time.Time `json:"launch_token_expires_at"`
+}
+
+// StudySessionResponse describes a session for listings. EndTime is when
+// the session ended, or its last activity while it is still active.
+type StudySessionResponse struct {
+	ID               int       `json:"id"`
+	ActivityName     string    `json:"activity_name"`
+	GroupName        string    `json:"group_name"`
+	Status           string    `json:"status"`
+	StartTime        time.Time `json:"start_time"`
+	EndTime          time.Time `json:"end_time"`
+	DurationSeconds  int       `json:"duration_seconds"`
+	ReviewItemsCount int       `json:"review_items_count"`
 }
 
+// StudyActivity is an app the portal can launch. LaunchURL is a template
+// whose placeholders (see LaunchPlaceholders) are filled in for each session;
+// PromptTypes are the review directions the app prompts words in.
 type StudyActivity struct {
-	ID           int    `json:"id"`
-	Name         string `json:"name"`
-	ThumbnailURL string `json:"thumbnail_url"`
-	Description  string `json:"description"`
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	ThumbnailURL string   `json:"thumbnail_url"`
+	Description  string   `json:"description"`
+	LaunchURL    string   `json:"launch_url"`
+	PromptTypes  []string `json:"prompt_types"`
+	Enabled      bool     `json:"enabled"`
 }
 
+// LaunchPlaceholders are the values a launch URL template may refer to.
+// URLs without {launch_token} get it as the launch_token query parameter.
+var LaunchPlaceholders = []string{"{study_session_id}", "{group_id}", "{study_activity_id}", "{launch_token}"}
+
+// Prompt directions a word can be reviewed in
+const (
+	DirectionJapaneseToEnglish = "jp_en"
+	DirectionEnglishToJapanese = "en_jp"
+	DirectionAudioToJapanese   = "audio_jp"
+)
+
 type WordReviewItem struct {
 	WordID         int       `json:"word_id"`
 	StudySessionID int       `json:"study_session_id"`
 	Correct        bool      `json:"correct"`
+	Grade          string    `json:"grade,omitempty"`
+	ResponseMs     *int      `json:"response_ms,omitempty"`
+	Answer         string    `json:"answer,omitempty"`
+	Direction      string    `json:"direction,omitempty"`
 	CreatedAt      time.Time `json:"created_at"`
-} 
\ No newline at end of file
+}
+
+type ReviewQueueItem struct {
+	Word
+	IsNew        bool       `json:"is_new"`
+	DueAt        *time.Time `json:"due_at"`
+	IntervalDays int        `json:"interval_days"`
+	EaseFactor   float64    `json:"ease_factor"`
+	Repetitions  int        `json:"repetitions"`
+	Lapses       int        `json:"lapses"`
+	Urgency      float64    `json:"urgency"`
+}
+
+// SessionWord is a word reviewed in a study session with its results in that
+// session. ReviewOrder is the position of its first review in the session.
+type SessionWord struct {
+	WordWithStats
+	ReviewOrder     int       `json:"review_order"`
+	FirstReviewedAt time.Time `json:"first_reviewed_at"`
+	LastReviewedAt  time.Time `json:"last_reviewed_at"`
+}
+
+// Verdicts of a server-checked answer
+const (
+	VerdictCorrect   = "correct"
+	VerdictTypo      = "typo"
+	VerdictIncorrect = "incorrect"
+)
+
+// AnswerResult is the outcome of checking a typed answer. Typos close
+// enough to an accepted answer still count as correct.
+type AnswerResult struct {
+	Correct         bool            `json:"correct"`
+	Verdict         string          `json:"verdict"`
+	Expected        string          `json:"expected"`
+	AcceptedAnswers []string        `json:"accepted_answers"`
+	Review          *WordReviewItem `json:"review"`
+}
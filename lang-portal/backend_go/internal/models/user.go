@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// User is a learner account. Study history, schedules and settings belong to
+// a user; words, groups and study activities are shared by everyone.
+type User struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	IsAdmin   bool      `json:"is_admin"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuthSession is a signed-in user with the token that authenticates them
+type AuthSession struct {
+	User      *User     `json:"user"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -1,25 +1,88 @@
 package models
 
+import (
+	"time"
+)
+
 type Word struct {
-    ID       int    `json:"id"`
-    Japanese string `json:"japanese"`
-    Romaji   string `json:"romaji"`
-    English  string `json:"english"`
+    ID       int        `json:"id"`
+    Japanese string     `json:"japanese"`
+    Romaji   string     `json:"romaji"`
+    English  string     `json:"english"`
+    Parts    []WordPart `json:"parts,omitempty"`
+}
+
+// WordPart is one character of a word. Kanji carry their on and kun
+// readings; Romaji holds the syllables the character is read as in this word.
+type WordPart struct {
+    Kanji  string   `json:"kanji"`
+    On     []string `json:"on,omitempty"`
+    Kun    []string `json:"kun,omitempty"`
+    Romaji []string `json:"romaji"`
 }
 
 type WordWithStats struct {
     Word
     CorrectCount int `json:"correct_count"`
     WrongCount   int `json:"wrong_count"`
+    // Mastery and Leech are only filled in by word listings
+    Mastery string `json:"mastery,omitempty"`
+    Leech   bool   `json:"leech,omitempty"`
+}
+
+// LeechWord is a word that has been failed repeatedly
+type LeechWord struct {
+    WordWithStats
+    Lapses         int        `json:"lapses"`
+    LastReviewedAt *time.Time `json:"last_reviewed_at"`
+}
+
+// GradeCounts breaks reviews down by the grade they were given;
+// reviews recorded with only a correct flag are not counted here
+type GradeCounts struct {
+    Again int `json:"again"`
+    Hard  int `json:"hard"`
+    Good  int `json:"good"`
+    Easy  int `json:"easy"`
 }
 
 type WordResponse struct {
+    ID       int    `json:"id"`
     Japanese string `json:"japanese"`
     Romaji   string `json:"romaji"`
     English  string `json:"english"`
+    Parts    []WordPart `json:"parts"`
     Stats    struct {
-        CorrectCount int `json:"correct_count"`
-        WrongCount   int `json:"wrong_count"`
+        CorrectCount      int         `json:"correct_count"`
+        WrongCount        int         `json:"wrong_count"`
+        Grades            GradeCounts `json:"grades"`
+        AverageResponseMs *float64    `json:"average_response_ms"`
+        Mastery           string      `json:"mastery"`
+        Lapses            int         `json:"lapses"`
+        Leech             bool        `json:"leech"`
     } `json:"stats"`
     Groups []Group `json:"groups"`
-} 
\ No newline at end of file
+}
+
+// Search match types, from most to least relevant
+const (
+    MatchExact    = "exact"
+    MatchRomaji   = "romaji"
+    MatchJapanese = "japanese"
+    MatchEnglish  = "english"
+    MatchFuzzy    = "fuzzy"
+)
+
+// WordHighlight repeats a word's fields with the matched text wrapped in
+// <mark></mark>
+type WordHighlight struct {
+    Japanese string `json:"japanese"`
+    Romaji   string `json:"romaji"`
+    English  string `json:"english"`
+}
+
+type WordSearchResult struct {
+    WordWithStats
+    Match     string        `json:"match"`
+    Highlight WordHighlight `json:"highlight"`
+}
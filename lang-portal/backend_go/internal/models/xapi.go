@@ -0,0 +1,111 @@
+package models
+
+import (
+	"encoding/json"
+)
+
+// XAPIVersion is the xAPI version the learning record store speaks
+const XAPIVersion = "1.0.3"
+
+// Verbs with a meaning in the portal. Other verbs are stored but have no
+// effect on study history.
+const (
+	XAPIVerbAnswered  = "http://adlnet.gov/expapi/verbs/answered"
+	XAPIVerbCompleted = "http://adlnet.gov/expapi/verbs/completed"
+	XAPIVerbVoided    = "http://adlnet.gov/expapi/verbs/voided"
+)
+
+// xAPI object types
+const (
+	XAPIObjectActivity     = "Activity"
+	XAPIObjectAgent        = "Agent"
+	XAPIObjectGroup        = "Group"
+	XAPIObjectStatementRef = "StatementRef"
+	XAPIObjectSubStatement = "SubStatement"
+)
+
+// XAPIStatement is the part of an xAPI statement the portal reads. The
+// statement itself is stored and returned exactly as it was sent.
+type XAPIStatement struct {
+	ID        string       `json:"id,omitempty"`
+	Actor     *XAPIAgent   `json:"actor"`
+	Verb      *XAPIVerb    `json:"verb"`
+	Object    *XAPIObject  `json:"object"`
+	Result    *XAPIResult  `json:"result,omitempty"`
+	Context   *XAPIContext `json:"context,omitempty"`
+	Timestamp string       `json:"timestamp,omitempty"`
+}
+
+// XAPIAgent is an agent or group, identified by exactly one of Mbox,
+// MboxSHA1Sum, OpenID or Account. Anonymous groups only list their members.
+type XAPIAgent struct {
+	ObjectType  string       `json:"objectType,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	Mbox        string       `json:"mbox,omitempty"`
+	MboxSHA1Sum string       `json:"mbox_sha1sum,omitempty"`
+	OpenID      string       `json:"openid,omitempty"`
+	Account     *XAPIAccount `json:"account,omitempty"`
+	Member      []XAPIAgent  `json:"member,omitempty"`
+}
+
+type XAPIAccount struct {
+	HomePage string `json:"homePage"`
+	Name     string `json:"name"`
+}
+
+type XAPIVerb struct {
+	ID      string            `json:"id"`
+	Display map[string]string `json:"display,omitempty"`
+}
+
+// XAPIObject is an activity, agent, statement reference or sub-statement;
+// only the type and id are read
+type XAPIObject struct {
+	ObjectType string `json:"objectType,omitempty"`
+	ID         string `json:"id,omitempty"`
+}
+
+type XAPIResult struct {
+	Success    *bool   `json:"success,omitempty"`
+	Completion *bool   `json:"completion,omitempty"`
+	Response   *string `json:"response,omitempty"`
+	Duration   string  `json:"duration,omitempty"`
+}
+
+type XAPIContext struct {
+	Registration      string                 `json:"registration,omitempty"`
+	ContextActivities *XAPIContextActivities `json:"contextActivities,omitempty"`
+}
+
+// XAPIContextActivities may hold a single activity or a list of them; both
+// are read as lists
+type XAPIContextActivities struct {
+	Parent   XAPIActivityList `json:"parent,omitempty"`
+	Grouping XAPIActivityList `json:"grouping,omitempty"`
+	Category XAPIActivityList `json:"category,omitempty"`
+	Other    XAPIActivityList `json:"other,omitempty"`
+}
+
+// XAPIActivityList reads either one activity or an array of them
+type XAPIActivityList []XAPIObject
+
+func (l *XAPIActivityList) UnmarshalJSON(data []byte) error {
+	var list []XAPIObject
+	if err := json.Unmarshal(data, &list); err == nil {
+		*l = list
+		return nil
+	}
+	var single XAPIObject
+	if err := json.Unmarshal(data, &single); err != nil {
+		return err
+	}
+	*l = XAPIActivityList{single}
+	return nil
+}
+
+// XAPIStatementResult is a page of statements. More is the URL of the next
+// page, or empty on the last one.
+type XAPIStatementResult struct {
+	Statements []json.RawMessage `json:"statements"`
+	More       string            `json:"more"`
+}
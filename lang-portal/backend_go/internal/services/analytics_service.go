@@ -0,0 +1,245 @@
+package services
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+)
+
+const (
+	// maxAnalyticsBuckets caps how many buckets one request may ask for
+	maxAnalyticsBuckets = 1000
+
+	// analyticsSlotSeconds is the granularity reviews are aggregated at in
+	// SQL before being folded into buckets. Every timezone offset is a
+	// multiple of 15 minutes, so no slot straddles two local days.
+	analyticsSlotSeconds = 15 * 60
+)
+
+// AnalyticsFilter selects the reviews to chart. From and To are calendar
+// dates (midnight UTC) in the learner's timezone; zero values default to a
+// recent range ending today. Zero IDs do not filter.
+type AnalyticsFilter struct {
+	From       time.Time
+	To         time.Time
+	Bucket     string
+	GroupID    int
+	ActivityID int
+}
+
+type AnalyticsService struct {
+	db *sql.DB
+}
+
+func NewAnalyticsService() *AnalyticsService {
+	return &AnalyticsService{db: database.DB}
+}
+
+// bucketStart returns the first day of the bucket containing date. Weeks
+// start on Monday.
+func bucketStart(date time.Time, bucket string) time.Time {
+	switch bucket {
+	case models.BucketWeek:
+		return date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+	case models.BucketMonth:
+		return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return date
+}
+
+// nextBucket returns the first day of the bucket after the one starting at start
+func nextBucket(start time.Time, bucket string) time.Time {
+	switch bucket {
+	case models.BucketWeek:
+		return start.AddDate(0, 0, 7)
+	case models.BucketMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// GetReviewAnalytics totals a learner's reviews, accuracy, newly introduced
+// words and time studied per day, week or month. A word is new in the bucket
+// holding the learner's first review of it ever; session time counts towards
+// the day it started. Buckets with no study are included so the series has
+// no gaps.
+func (s *AnalyticsService) GetReviewAnalytics(userID int, filter AnalyticsFilter) (*models.ReviewAnalytics, error) {
+	if filter.Bucket == "" {
+		filter.Bucket = models.BucketDay
+	}
+	if filter.Bucket != models.BucketDay && filter.Bucket != models.BucketWeek && filter.Bucket != models.BucketMonth {
+		return nil, &ValidationError{Field: "bucket", Message: "must be one of day, week or month"}
+	}
+	if filter.GroupID != 0 {
+		if found, err := exists(s.db, "groups", filter.GroupID); err != nil {
+			return nil, err
+		} else if !found {
+			return nil, ErrGroupNotFound
+		}
+	}
+	if filter.ActivityID != 0 {
+		if found, err := exists(s.db, "study_activities", filter.ActivityID); err != nil {
+			return nil, err
+		} else if !found {
+			return nil, ErrStudyActivityNotFound
+		}
+	}
+
+	settings, err := NewSettingsService().GetSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+	loc := settingsLocation(settings)
+
+	if filter.To.IsZero() {
+		filter.To = civilDate(time.Now(), loc)
+	}
+	if filter.From.IsZero() {
+		switch filter.Bucket {
+		case models.BucketWeek:
+			filter.From = bucketStart(filter.To, filter.Bucket).AddDate(0, 0, -7*11)
+		case models.BucketMonth:
+			filter.From = bucketStart(filter.To, filter.Bucket).AddDate(0, -11, 0)
+		default:
+			filter.From = filter.To.AddDate(0, 0, -29)
+		}
+	}
+	if filter.To.Before(filter.From) {
+		return nil, &ValidationError{Field: "to", Message: "must not be before from"}
+	}
+
+	// Lay out every bucket in the range, clamping the first and last to it
+	analytics := &models.ReviewAnalytics{
+		From:     filter.From.Format("2006-01-02"),
+		To:       filter.To.Format("2006-01-02"),
+		Bucket:   filter.Bucket,
+		Timezone: loc.String(),
+		Items:    make([]models.AnalyticsBucket, 0),
+	}
+	index := map[time.Time]int{}
+	for start := bucketStart(filter.From, filter.Bucket); !start.After(filter.To); start = nextBucket(start, filter.Bucket) {
+		if len(analytics.Items) == maxAnalyticsBuckets {
+			return nil, &ValidationError{Field: "from", Message: "range has too many buckets; use a larger bucket or a shorter range"}
+		}
+		first, last := start, nextBucket(start, filter.Bucket).AddDate(0, 0, -1)
+		if first.Before(filter.From) {
+			first = filter.From
+		}
+		if last.After(filter.To) {
+			last = filter.To
+		}
+		index[start] = len(analytics.Items)
+		analytics.Items = append(analytics.Items, models.AnalyticsBucket{
+			Start: first.Format("2006-01-02"),
+			End:   last.Format("2006-01-02"),
+		})
+	}
+	bucketFor := func(slot int64) *models.AnalyticsBucket {
+		date := civilDate(time.Unix(slot*analyticsSlotSeconds, 0), loc)
+		if i, ok := index[bucketStart(date, filter.Bucket)]; ok {
+			return &analytics.Items[i]
+		}
+		return nil
+	}
+
+	// The range runs from midnight on From to midnight after To, locally
+	rangeStart := time.Date(filter.From.Year(), filter.From.Month(), filter.From.Day(), 0, 0, 0, 0, loc).Unix()
+	rangeEnd := time.Date(filter.To.Year(), filter.To.Month(), filter.To.Day()+1, 0, 0, 0, 0, loc).Unix()
+
+	where := " AND ss.user_id = ?"
+	args := []interface{}{rangeStart, rangeEnd, userID}
+	if filter.GroupID != 0 {
+		where += " AND ss.group_id = ?"
+		args = append(args, filter.GroupID)
+	}
+	if filter.ActivityID != 0 {
+		where += " AND ss.study_activity_id = ?"
+		args = append(args, filter.ActivityID)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT
+			unixepoch(wri.created_at) / `+strconv.Itoa(analyticsSlotSeconds)+` as slot,
+			COUNT(*) as reviews,
+			COUNT(CASE WHEN wri.correct = 1 THEN 1 END) as correct_count,
+			COUNT(DISTINCT CASE WHEN NOT EXISTS (
+				SELECT 1 FROM word_review_items prev
+				WHERE prev.word_id = wri.word_id AND prev.user_id = wri.user_id
+				AND unixepoch(prev.created_at) < unixepoch(wri.created_at)
+			) THEN wri.word_id END) as new_words
+		FROM word_review_items wri
+		JOIN study_sessions ss ON ss.id = wri.study_session_id
+		WHERE unixepoch(wri.created_at) >= ? AND unixepoch(wri.created_at) < ?`+where+`
+		GROUP BY slot
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var slot int64
+		var reviews, correct, newWords int
+		if err := rows.Scan(&slot, &reviews, &correct, &newWords); err != nil {
+			return nil, err
+		}
+		if bucket := bucketFor(slot); bucket != nil {
+			bucket.Reviews += reviews
+			bucket.CorrectCount += correct
+			bucket.NewWords += newWords
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.db.Query(`
+		SELECT
+			unixepoch(ss.created_at) / `+strconv.Itoa(analyticsSlotSeconds)+` as slot,
+			COUNT(*) as study_sessions,
+			COALESCE(SUM(MAX(unixepoch(COALESCE(ss.ended_at, ss.last_activity_at, ss.created_at)) - unixepoch(ss.created_at), 0)), 0) as study_seconds
+		FROM study_sessions ss
+		WHERE unixepoch(ss.created_at) >= ? AND unixepoch(ss.created_at) < ?`+where+`
+		GROUP BY slot
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer sessions.Close()
+	for sessions.Next() {
+		var slot int64
+		var count, seconds int
+		if err := sessions.Scan(&slot, &count, &seconds); err != nil {
+			return nil, err
+		}
+		if bucket := bucketFor(slot); bucket != nil {
+			bucket.StudySessions += count
+			bucket.StudySeconds += seconds
+		}
+	}
+	if err := sessions.Err(); err != nil {
+		return nil, err
+	}
+
+	analytics.Totals = models.AnalyticsBucket{Start: analytics.From, End: analytics.To}
+	for i := range analytics.Items {
+		bucket := &analytics.Items[i]
+		bucket.Accuracy = accuracy(bucket.CorrectCount, bucket.Reviews)
+		analytics.Totals.Reviews += bucket.Reviews
+		analytics.Totals.CorrectCount += bucket.CorrectCount
+		analytics.Totals.NewWords += bucket.NewWords
+		analytics.Totals.StudySessions += bucket.StudySessions
+		analytics.Totals.StudySeconds += bucket.StudySeconds
+	}
+	analytics.Totals.Accuracy = accuracy(analytics.Totals.CorrectCount, analytics.Totals.Reviews)
+	return analytics, nil
+}
+
+// accuracy returns correct as a percentage of total, or 0 when there is nothing to rate
+func accuracy(correct, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(correct) * 100 / float64(total)
+}
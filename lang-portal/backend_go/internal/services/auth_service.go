@@ -0,0 +1,197 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"time"
+	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// LocalUserID is the learner single-user mode studies as. It owns the
+// history studied before accounts existed.
+const LocalUserID = 1
+
+// minPasswordLength is the shortest password an account may have
+const minPasswordLength = 8
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// authTokenTTL is how long a sign-in lasts
+var authTokenTTL = 30 * 24 * time.Hour
+
+// ConfigureAuth sets how long a sign-in token stays valid after login
+func ConfigureAuth(ttl time.Duration) {
+	authTokenTTL = ttl
+}
+
+type AuthService struct {
+	db *sql.DB
+}
+
+func NewAuthService() *AuthService {
+	return &AuthService{db: database.DB}
+}
+
+// hashAuthToken returns the form sign-in tokens are stored in
+func hashAuthToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func scanUser(row interface{ Scan(...interface{}) error }) (*models.User, error) {
+	var user models.User
+	if err := row.Scan(&user.ID, &user.Username, &user.IsAdmin, &user.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Register creates an account. The first account registered claims the local
+// learner, with everything studied in single-user mode, and administers the
+// portal; later accounts are plain learners.
+func (s *AuthService) Register(username, password string) (*models.User, error) {
+	username = strings.TrimSpace(username)
+	if !usernamePattern.MatchString(username) {
+		return nil, &ValidationError{Field: "username", Message: "must be 3 to 32 letters, digits, dots, dashes or underscores"}
+	}
+	if len(password) < minPasswordLength {
+		return nil, &ValidationError{Field: "password", Message: "must be at least 8 characters long"}
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, &ValidationError{Field: "password", Message: "must be at most 72 bytes long"}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var existing int
+	err = tx.QueryRow("SELECT id FROM users WHERE username = ? AND password_hash != ''", username).Scan(&existing)
+	if err == nil {
+		return nil, ErrDuplicateUser
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	result, err := tx.Exec(
+		"UPDATE users SET username = ?, password_hash = ? WHERE id = ? AND password_hash = ''",
+		username, string(hash), LocalUserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id := int64(LocalUserID)
+	if claimed, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if claimed == 0 {
+		result, err = tx.Exec(
+			"INSERT INTO users (username, password_hash, created_at) VALUES (?, ?, ?)",
+			username, string(hash), time.Now(),
+		)
+		if err != nil {
+			return nil, err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := scanUser(tx.QueryRow("SELECT id, username, is_admin, created_at FROM users WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login checks a username and password and issues a sign-in token for them.
+// Unknown users and wrong passwords both return ErrInvalidCredentials.
+func (s *AuthService) Login(username, password string) (*models.AuthSession, error) {
+	var user models.User
+	var hash string
+	err := s.db.QueryRow(`
+		SELECT id, username, is_admin, created_at, password_hash
+		FROM users
+		WHERE username = ?
+	`, strings.TrimSpace(username)).Scan(&user.ID, &user.Username, &user.IsAdmin, &user.CreatedAt, &hash)
+	if err == sql.ErrNoRows || (err == nil && hash == "") {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	now := time.Now()
+	session := &models.AuthSession{
+		User:      &user,
+		Token:     rand.Text(),
+		ExpiresAt: now.Add(authTokenTTL),
+	}
+
+	// Expired tokens are only ever looked up to be rejected, so drop them
+	_, err = s.db.Exec(
+		"DELETE FROM auth_tokens WHERE user_id = ? AND julianday(expires_at) <= julianday(?)",
+		user.ID, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.db.Exec(`
+		INSERT INTO auth_tokens (token_hash, user_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, hashAuthToken(session.Token), user.ID, now, session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Authenticate returns the user a sign-in token was issued to, or
+// ErrAuthTokenInvalid when it is unknown, signed out or expired
+func (s *AuthService) Authenticate(token string) (*models.User, error) {
+	user, err := scanUser(s.db.QueryRow(`
+		SELECT u.id, u.username, u.is_admin, u.created_at
+		FROM auth_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = ? AND julianday(t.expires_at) > julianday(?)
+	`, hashAuthToken(token), time.Now()))
+	if err == sql.ErrNoRows {
+		return nil, ErrAuthTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Logout revokes a sign-in token
+func (s *AuthService) Logout(token string) error {
+	_, err := s.db.Exec("DELETE FROM auth_tokens WHERE token_hash = ?", hashAuthToken(token))
+	return err
+}
+
+func (s *AuthService) GetUser(id int) (*models.User, error) {
+	user, err := scanUser(s.db.QueryRow("SELECT id, username, is_admin, created_at FROM users WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"github.com/mattn/go-sqlite3"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+)
+
+var (
+	ErrGroupNotFound            = errors.New("group not found")
+	ErrStudyActivityNotFound    = errors.New("study activity not found")
+	ErrStudyActivityDisabled    = errors.New("study activity is disabled")
+	ErrDuplicateStudyActivity   = errors.New("study activity already exists")
+	ErrStudyActivityHasSessions = errors.New("study activity has study sessions")
+	ErrStudySessionNotFound     = errors.New("study session not found")
+	ErrStudySessionEnded        = errors.New("study session has ended")
+	ErrWordNotFound             = errors.New("word not found")
+	ErrDuplicateWord            = errors.New("word already exists")
+	ErrDuplicateGroup           = errors.New("group already exists")
+	ErrGroupHasSessions         = errors.New("group has study sessions")
+	ErrStatementNotFound        = errors.New("statement not found")
+	ErrStatementConflict        = errors.New("a different statement with this id already exists")
+	ErrLaunchTokenRequired      = errors.New("launch token required")
+	ErrLaunchTokenInvalid       = errors.New("launch token is invalid or has expired")
+	ErrLaunchTokenScope         = errors.New("launch token is for another study session")
+	ErrUserNotFound             = errors.New("user not found")
+	ErrDuplicateUser            = errors.New("username is already taken")
+	ErrInvalidCredentials       = errors.New("invalid username or password")
+	ErrAuthTokenInvalid         = errors.New("sign-in token is invalid or has expired")
+)
+
+// ValidationError reports invalid input for a single field
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// learnerCTE is a CTE named learner holding the id of the learner whose
+// study history a query reads, bound to its single placeholder. It serves
+// expressions such as sort columns that cannot take arguments of their own.
+const learnerCTE = "WITH learner(id) AS (SELECT ?) "
+
+// Sortable columns for group listings, keyed by the sort_by query value.
+// Study history is read for the learner in learnerCTE.
+var groupSortColumns = map[string]string{
+	"name":       "g.name",
+	"word_count": "word_count",
+	"words_studied": `(SELECT COUNT(DISTINCT wri.word_id)
+		FROM word_review_items wri
+		JOIN words_groups studied ON studied.word_id = wri.word_id
+		WHERE studied.group_id = g.id AND wri.user_id = (SELECT id FROM learner))`,
+	"last_studied_at": `(SELECT MAX(unixepoch(COALESCE(ss.last_activity_at, ss.created_at)))
+		FROM study_sessions ss
+		WHERE ss.group_id = g.id AND ss.user_id = (SELECT id FROM learner))`,
+}
+
+// Sortable columns for word listings, keyed by the sort_by query value
+var wordSortColumns = map[string]string{
+	"japanese":      "w.japanese",
+	"kanji":         "w.japanese",
+	"romaji":        "w.romaji",
+	"english":       "w.english",
+	"correct_count": "correct_count",
+	"wrong_count":   "wrong_count",
+}
+
+// orderClause builds a safe ORDER BY clause from user supplied sort options,
+// falling back to the given default column for unknown values
+func orderClause(columns map[string]string, sortBy, order, fallback string) string {
+	column, ok := columns[sortBy]
+	if !ok {
+		column = fallback
+	}
+	direction := "ASC"
+	if order == "desc" {
+		direction = "DESC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s", column, direction)
+}
+
+// newPaginatedResponse wraps a page of items with its pagination metadata
+func newPaginatedResponse(items interface{}, page, perPage, total int) *models.PaginatedResponse {
+	return &models.PaginatedResponse{
+		Items: items,
+		Pagination: models.Pagination{
+			CurrentPage:  page,
+			TotalPages:   (total + perPage - 1) / perPage,
+			TotalItems:   total,
+			ItemsPerPage: perPage,
+		},
+	}
+}
+
+// parseTime converts a timestamp produced by an aggregate or COALESCE
+// expression, which SQLite returns as plain text, into a time.Time
+func parseTime(value string) time.Time {
+	value = strings.TrimSuffix(value, "Z")
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(format, value, time.UTC); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// exists reports whether a row with the given id is present in table
+func exists(db *sql.DB, table string, id int) (bool, error) {
+	var found int
+	err := db.QueryRow("SELECT 1 FROM "+table+" WHERE id = ?", id).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// nullIntPtr converts a nullable integer column into an optional int
+func nullIntPtr(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	v := int(value.Int64)
+	return &v
+}
+
+// inClause builds "column IN (?, ?, ...)" with one placeholder per id
+func inClause(column string, ids []int) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return column + " IN (" + strings.Join(placeholders, ", ") + ")", args
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
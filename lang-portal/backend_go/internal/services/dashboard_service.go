@@ -2,6 +2,7 @@ package services
 
 import (
 	"database/sql"
+	"time"
 	"github.com/mohawa/lang-portal/backend_go/internal/database"
 	"github.com/mohawa/lang-portal/backend_go/internal/models"
 )
@@ -14,24 +15,35 @@ func NewDashboardService() *DashboardService {
 	return &DashboardService{db: database.DB}
 }
 
-func (s *DashboardService) GetLastStudySession() (*models.StudySession, error) {
+// GetLastStudySession returns the learner's most recently started study
+// session, or nil when there are none yet
+func (s *DashboardService) GetLastStudySession(userID int) (*models.StudySession, error) {
 	var session models.StudySession
+	var endedAt sql.NullTime
 	err := s.db.QueryRow(`
 		SELECT 
 			ss.id,
 			ss.group_id,
 			ss.created_at,
 			ss.study_activity_id,
+			ss.status,
+			ss.ended_at,
+			COALESCE(sa.name, '') as activity_name,
 			g.name as group_name
 		FROM study_sessions ss
 		JOIN groups g ON ss.group_id = g.id
-		ORDER BY ss.created_at DESC
+		LEFT JOIN study_activities sa ON ss.study_activity_id = sa.id
+		WHERE ss.user_id = ?
+		ORDER BY ss.created_at DESC, ss.id DESC
 		LIMIT 1
-	`).Scan(
+	`, userID).Scan(
 		&session.ID,
 		&session.GroupID,
 		&session.CreatedAt,
 		&session.StudyActivityID,
+		&session.Status,
+		&endedAt,
+		&session.ActivityName,
 		&session.GroupName,
 	)
 	if err == sql.ErrNoRows {
@@ -40,10 +52,13 @@ func (s *DashboardService) GetLastStudySession() (*models.StudySession, error) {
 	if err != nil {
 		return nil, err
 	}
+	if endedAt.Valid {
+		session.EndedAt = &endedAt.Time
+	}
 	return &session, nil
 }
 
-func (s *DashboardService) GetStudyProgress() (*models.StudyProgress, error) {
+func (s *DashboardService) GetStudyProgress(userID int) (*models.StudyProgress, error) {
 	var progress models.StudyProgress
 
 	err := s.db.QueryRow(`
@@ -56,7 +71,22 @@ func (s *DashboardService) GetStudyProgress() (*models.StudyProgress, error) {
 	err = s.db.QueryRow(`
 		SELECT COUNT(DISTINCT word_id) 
 		FROM word_review_items
-	`).Scan(&progress.TotalWordsStudied)
+		WHERE user_id = ?
+	`, userID).Scan(&progress.TotalWordsStudied)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRow(`
+		SELECT
+			CAST(COALESCE(SUM(seconds), 0) AS INTEGER),
+			COALESCE(AVG(seconds), 0)
+		FROM (
+			SELECT (julianday(COALESCE(ended_at, last_activity_at, created_at)) - julianday(created_at)) * 86400 as seconds
+			FROM study_sessions
+			WHERE user_id = ?
+		)
+	`, userID).Scan(&progress.TotalStudySeconds, &progress.AverageSessionSeconds)
 	if err != nil {
 		return nil, err
 	}
@@ -64,7 +94,7 @@ func (s *DashboardService) GetStudyProgress() (*models.StudyProgress, error) {
 	return &progress, nil
 }
 
-func (s *DashboardService) GetQuickStats() (*models.DashboardStats, error) {
+func (s *DashboardService) GetQuickStats(userID int) (*models.DashboardStats, error) {
 	var stats models.DashboardStats
 
 	err := s.db.QueryRow(`
@@ -75,14 +105,31 @@ func (s *DashboardService) GetQuickStats() (*models.DashboardStats, error) {
 				0
 			)
 		FROM word_review_items
-	`).Scan(&stats.SuccessRate)
+		WHERE user_id = ?
+	`, userID).Scan(&stats.SuccessRate)
 	if err != nil {
 		return nil, err
 	}
 
 	err = s.db.QueryRow(`
-		SELECT COUNT(*) FROM study_sessions
-	`).Scan(&stats.TotalStudySessions)
+		SELECT
+			COALESCE(AVG(response_ms), 0),
+			COUNT(grade),
+			COALESCE(
+				CAST(SUM(CASE WHEN grade = 'easy' THEN 1 ELSE 0 END) AS FLOAT) /
+				NULLIF(COUNT(grade), 0) * 100,
+				0
+			)
+		FROM word_review_items
+		WHERE user_id = ?
+	`, userID).Scan(&stats.AverageResponseMs, &stats.GradedReviews, &stats.EasyRate)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRow(`
+		SELECT COUNT(*) FROM study_sessions WHERE user_id = ?
+	`, userID).Scan(&stats.TotalStudySessions)
 	if err != nil {
 		return nil, err
 	}
@@ -90,29 +137,27 @@ func (s *DashboardService) GetQuickStats() (*models.DashboardStats, error) {
 	err = s.db.QueryRow(`
 		SELECT COUNT(DISTINCT group_id) 
 		FROM study_sessions
-	`).Scan(&stats.TotalActiveGroups)
+		WHERE user_id = ?
+	`, userID).Scan(&stats.TotalActiveGroups)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.db.QueryRow(`
-		WITH RECURSIVE dates(date) AS (
-			SELECT date(MAX(created_at)) FROM study_sessions
-			UNION ALL
-			SELECT date(date, '-1 day')
-			FROM dates
-			WHERE EXISTS (
-				SELECT 1 FROM study_sessions 
-				WHERE date(created_at) = date(dates.date, '-1 day')
-			)
-		)
-		SELECT COUNT(*) FROM dates
-	`).Scan(&stats.StudyStreakDays)
+	settings, err := NewSettingsService().GetSettings(userID)
 	if err != nil {
 		return nil, err
 	}
+	days, err := loadStudyDays(s.db, userID, settingsLocation(settings))
+	if err != nil {
+		return nil, err
+	}
+	streaks := summarizeStreaks(days, settings, time.Now())
+	stats.StudyStreakDays = streaks.current
+	stats.CurrentStreakDays = streaks.current
+	stats.LongestStreakDays = streaks.longest
+	stats.StreakFreezesUsed = streaks.freezesUsed
+	stats.StudiedToday = streaks.today.Reviews > 0
+	stats.Today = streaks.today
 
 	return &stats, nil
 }
-
-// ... rest of the implementation ... 
\ No newline at end of file
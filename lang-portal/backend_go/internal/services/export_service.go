@@ -0,0 +1,177 @@
+package services
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+)
+
+type ExportService struct {
+	db *sql.DB
+}
+
+func NewExportService() *ExportService {
+	return &ExportService{db: database.DB}
+}
+
+// groupNameSeparator joins a word's group names in SQL; it cannot appear in a name
+const groupNameSeparator = "\x1f"
+
+// ExportWords returns every word, or only those in the group when groupID is
+// not zero, with its groups and a learner's review counts, mastery and schedule
+func (s *ExportService) ExportWords(userID, groupID int) (*models.WordExport, error) {
+	export := &models.WordExport{
+		ExportedAt: time.Now().UTC(),
+		Words:      make([]models.ExportedWord, 0),
+	}
+
+	where := ""
+	args := []interface{}{userID, userID}
+	if groupID != 0 {
+		var name string
+		err := s.db.QueryRow("SELECT name FROM groups WHERE id = ?", groupID).Scan(&name)
+		if err == sql.ErrNoRows {
+			return nil, ErrGroupNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		export.GroupID = &groupID
+		export.GroupName = name
+		where = "WHERE w.id IN (SELECT word_id FROM words_groups WHERE group_id = ?)"
+		args = append(args, groupID)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT w.id, w.japanese, w.romaji, w.english,
+			   (
+				   SELECT GROUP_CONCAT(name, '`+groupNameSeparator+`') FROM (
+					   SELECT DISTINCT g.name FROM words_groups wg
+					   JOIN groups g ON g.id = wg.group_id
+					   WHERE wg.word_id = w.id
+					   ORDER BY g.name
+				   )
+			   ) as group_names,
+			   COUNT(CASE WHEN wri.correct = 1 THEN 1 END) as correct_count,
+			   COUNT(CASE WHEN wri.correct = 0 THEN 1 END) as wrong_count,
+			   `+masteryColumn+` as mastery,
+			   `+lapsesColumn+` as lapses,
+			   `+leechColumn+` as leech,
+			   ws.ease_factor, ws.interval_days, ws.repetitions, ws.due_at,
+			   MAX(wri.created_at) as last_reviewed_at
+		FROM words w`+learnerJoins+`
+		`+where+`
+		GROUP BY w.id
+		ORDER BY w.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w models.ExportedWord
+		var groupNames, dueAt, lastReviewedAt sql.NullString
+		var easeFactor sql.NullFloat64
+		var intervalDays, repetitions sql.NullInt64
+		if err := rows.Scan(
+			&w.ID, &w.Japanese, &w.Romaji, &w.English, &groupNames,
+			&w.CorrectCount, &w.WrongCount, &w.Mastery, &w.Lapses, &w.Leech,
+			&easeFactor, &intervalDays, &repetitions, &dueAt, &lastReviewedAt,
+		); err != nil {
+			return nil, err
+		}
+		w.Groups = make([]string, 0)
+		if groupNames.Valid {
+			w.Groups = strings.Split(groupNames.String, groupNameSeparator)
+		}
+		if easeFactor.Valid {
+			w.EaseFactor = &easeFactor.Float64
+		}
+		w.IntervalDays = nullIntPtr(intervalDays)
+		w.Repetitions = nullIntPtr(repetitions)
+		if dueAt.Valid {
+			t := parseTime(dueAt.String)
+			w.DueAt = &t
+		}
+		if lastReviewedAt.Valid {
+			t := parseTime(lastReviewedAt.String)
+			w.LastReviewedAt = &t
+		}
+		export.Words = append(export.Words, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// ExportReviews returns a learner's whole review log, oldest first, or only
+// the reviews made in sessions of the group when groupID is not zero
+func (s *ExportService) ExportReviews(userID, groupID int) (*models.ReviewExport, error) {
+	export := &models.ReviewExport{
+		ExportedAt: time.Now().UTC(),
+		Reviews:    make([]models.ExportedReview, 0),
+	}
+
+	where := "WHERE wri.user_id = ?"
+	args := []interface{}{userID}
+	if groupID != 0 {
+		if found, err := exists(s.db, "groups", groupID); err != nil {
+			return nil, err
+		} else if !found {
+			return nil, ErrGroupNotFound
+		}
+		export.GroupID = &groupID
+		where += " AND ss.group_id = ?"
+		args = append(args, groupID)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT wri.word_id, w.japanese, w.romaji, w.english,
+			   wri.study_session_id, ss.group_id, ss.study_activity_id,
+			   wri.correct, wri.grade, wri.direction, wri.answer, wri.response_ms,
+			   wri.created_at
+		FROM word_review_items wri
+		JOIN words w ON w.id = wri.word_id
+		JOIN study_sessions ss ON ss.id = wri.study_session_id
+		`+where+`
+		ORDER BY unixepoch(wri.created_at), wri.rowid
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.ExportedReview
+		var activityID, responseMs sql.NullInt64
+		var grade, direction, answer sql.NullString
+		if err := rows.Scan(
+			&r.WordID, &r.Japanese, &r.Romaji, &r.English,
+			&r.StudySessionID, &r.GroupID, &activityID,
+			&r.Correct, &grade, &direction, &answer, &responseMs,
+			&r.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		r.StudyActivityID = nullIntPtr(activityID)
+		r.ResponseMs = nullIntPtr(responseMs)
+		if grade.Valid {
+			r.Grade = &grade.String
+		}
+		if direction.Valid {
+			r.Direction = &direction.String
+		}
+		if answer.Valid {
+			r.Answer = &answer.String
+		}
+		export.Reviews = append(export.Reviews, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
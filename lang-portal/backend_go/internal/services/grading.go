@@ -0,0 +1,123 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"github.com/mohawa/lang-portal/backend_go/internal/kana"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+	"github.com/mohawa/lang-portal/backend_go/internal/srs"
+)
+
+var (
+	// meaningSeparator splits an english field into alternate meanings
+	meaningSeparator = regexp.MustCompile(`[;,/]`)
+	// parenthetical matches notes such as "(polite)" that are not part of the answer
+	parenthetical = regexp.MustCompile(`\([^)]*\)`)
+)
+
+// acceptedMeanings lists the meanings an english field accepts, so
+// "hello; hi (casual)" accepts both "hello" and "hi"
+func acceptedMeanings(english string) []string {
+	meanings := make([]string, 0)
+	for _, meaning := range meaningSeparator.Split(parenthetical.ReplaceAllString(english, ""), -1) {
+		if meaning = strings.TrimSpace(meaning); meaning != "" {
+			meanings = append(meanings, meaning)
+		}
+	}
+	return meanings
+}
+
+// meaningKey reduces an english answer to a comparison key, ignoring case,
+// punctuation, articles and the "to" of verbs
+func meaningKey(s string) string {
+	s = parenthetical.ReplaceAllString(strings.ToLower(kana.NormalizeWidth(s)), "")
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for len(words) > 1 {
+		switch words[0] {
+		case "to", "a", "an", "the":
+			words = words[1:]
+			continue
+		}
+		break
+	}
+	return strings.Join(words, " ")
+}
+
+// typoTolerance is how many edits an answer whose key has the given length
+// may be off by and still be accepted
+func typoTolerance(length int) int {
+	switch {
+	case length >= 8:
+		return 2
+	case length >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// closeEnough reports whether two comparison keys are within typo tolerance
+func closeEnough(answer, expected string) bool {
+	a, b := []rune(answer), []rune(expected)
+	return len(b) > 0 && editDistance(a, b) <= typoTolerance(len(b))
+}
+
+// gradeAnswer checks a typed answer against a word. The direction decides
+// which side of the word is expected; without one, any side is accepted.
+func gradeAnswer(word models.Word, answer, direction string) *models.AnswerResult {
+	result := &models.AnswerResult{Verdict: models.VerdictIncorrect, AcceptedAnswers: make([]string, 0)}
+	checkJapanese := direction != models.DirectionJapaneseToEnglish
+	checkEnglish := direction == "" || direction == models.DirectionJapaneseToEnglish
+
+	verdict := func(v string) {
+		if v == models.VerdictCorrect || (v == models.VerdictTypo && result.Verdict == models.VerdictIncorrect) {
+			result.Verdict = v
+		}
+	}
+
+	if checkJapanese {
+		result.Expected = word.Japanese
+		result.AcceptedAnswers = append(result.AcceptedAnswers, word.Japanese, word.Romaji)
+		if readingMatches(word, answer) {
+			verdict(models.VerdictCorrect)
+		} else if !strings.ContainsFunc(answer, func(r rune) bool { return unicode.Is(unicode.Han, r) }) &&
+			closeEnough(kana.ReadingKey(answer), kana.ReadingKey(word.Romaji)) {
+			verdict(models.VerdictTypo)
+		}
+	}
+
+	if checkEnglish {
+		if !checkJapanese {
+			result.Expected = word.English
+		}
+		key := meaningKey(answer)
+		for _, meaning := range acceptedMeanings(word.English) {
+			result.AcceptedAnswers = append(result.AcceptedAnswers, meaning)
+			expected := meaningKey(meaning)
+			if key == expected {
+				verdict(models.VerdictCorrect)
+			} else if closeEnough(key, expected) {
+				verdict(models.VerdictTypo)
+			}
+		}
+	}
+
+	result.Correct = result.Verdict != models.VerdictIncorrect
+	return result
+}
+
+// answerGrade turns a verdict into the review grade that drives the schedule:
+// a typo is remembered with difficulty
+func answerGrade(verdict string) srs.Grade {
+	switch verdict {
+	case models.VerdictCorrect:
+		return srs.GradeGood
+	case models.VerdictTypo:
+		return srs.GradeHard
+	default:
+		return srs.GradeAgain
+	}
+}
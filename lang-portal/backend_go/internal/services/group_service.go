@@ -2,7 +2,25 @@ package services
 
 import (
 	"database/sql"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+	"github.com/mohawa/lang-portal/backend_go/internal/srs"
+)
+
+// DefaultRecentSessions is how many of a group's latest sessions its recent
+// accuracy covers unless asked otherwise: enough reviews to smooth out one
+// bad session, few enough that accuracy reflects current form rather than
+// the group's whole history
+const DefaultRecentSessions = 5
+
+const (
+	// groupPaceDays is the window over which the pace of studying new words
+	// is measured to estimate when a group will be completed
+	groupPaceDays = 28
 )
 
 type GroupService struct {
@@ -15,21 +33,663 @@ func NewGroupService() *GroupService {
 	}
 }
 
-func (s *GroupService) GetGroup(id int) (map[string]interface{}, error) {
-	var name string
-	err := s.db.QueryRow("SELECT name FROM groups WHERE id = ?", id).Scan(&name)
+func (s *GroupService) groupExists(id int) error {
+	found, err := exists(s.db, "groups", id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+// groupTree returns a CTE named tree holding the group bound to its single
+// placeholder, plus all of its descendants when includeDescendants is set
+func groupTree(includeDescendants bool) string {
+	if !includeDescendants {
+		return "WITH tree(id) AS (SELECT ?) "
+	}
+	return `
+		WITH RECURSIVE tree(id) AS (
+			SELECT ?
+			UNION
+			SELECT g.id FROM groups g JOIN tree t ON g.parent_id = t.id
+		) `
+}
+
+func scanGroups(rows *sql.Rows) ([]models.Group, error) {
+	defer rows.Close()
+
+	groups := make([]models.Group, 0)
+	for rows.Next() {
+		var g models.Group
+		var parentID sql.NullInt64
+		if err := rows.Scan(&g.ID, &g.Name, &parentID, &g.WordCount); err != nil {
+			return nil, err
+		}
+		g.ParentID = nullIntPtr(parentID)
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// GetGroups lists groups with a learner's progress through them. When
+// parentID is set only its direct subgroups are returned, with 0 selecting
+// top-level groups.
+func (s *GroupService) GetGroups(userID, page, perPage int, sortBy, order string, parentID *int, recentSessions int) (*models.PaginatedResponse, error) {
+	where := ""
+	args := []interface{}{}
+	if parentID != nil {
+		if *parentID == 0 {
+			where = "WHERE g.parent_id IS NULL"
+		} else {
+			where = "WHERE g.parent_id = ?"
+			args = append(args, *parentID)
+		}
+	}
+
+	var total int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM groups g "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := (page - 1) * perPage
+	rows, err := s.db.Query(learnerCTE+`
+		SELECT g.id, g.name, g.parent_id, COUNT(wg.word_id) as word_count
+		FROM groups g
+		LEFT JOIN words_groups wg ON g.id = wg.group_id
+		`+where+`
+		GROUP BY g.id
+		`+orderClause(groupSortColumns, sortBy, order, "g.name")+`
+		LIMIT ? OFFSET ?
+	`, append(append([]interface{}{userID}, args...), perPage, offset)...)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := scanGroups(rows)
+	if err != nil {
+		return nil, err
+	}
+	scopes := make(map[int][]int, len(groups))
+	for _, group := range groups {
+		scopes[group.ID] = []int{group.ID}
+	}
+	stats, err := s.groupStats(userID, scopes, recentSessions)
+	if err != nil {
+		return nil, err
+	}
+	for i := range groups {
+		groups[i].Stats = stats[groups[i].ID]
+	}
+
+	return newPaginatedResponse(groups, page, perPage, total), nil
+}
+
+// GetGroup returns a group with its direct subgroups and a learner's progress
+// through it. With includeDescendants the word count covers the distinct
+// words of the whole subtree.
+func (s *GroupService) GetGroup(userID, id int, includeDescendants bool, recentSessions int) (*models.GroupResponse, error) {
+	var group models.GroupResponse
+	var parentID sql.NullInt64
+	err := s.db.QueryRow("SELECT id, name, parent_id FROM groups WHERE id = ?", id).
+		Scan(&group.ID, &group.Name, &parentID)
 	if err == sql.ErrNoRows {
-		return nil, sql.ErrNoRows
+		return nil, ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	group.ParentID = nullIntPtr(parentID)
+
+	groupIDs, err := s.subtreeIDs(id, includeDescendants)
+	if err != nil {
+		return nil, err
 	}
+	stats, err := s.groupStats(userID, map[int][]int{id: groupIDs}, recentSessions)
 	if err != nil {
 		return nil, err
 	}
+	group.Stats = *stats[id]
+
+	rows, err := s.db.Query(`
+		SELECT g.id, g.name, g.parent_id, COUNT(wg.word_id) as word_count
+		FROM groups g
+		LEFT JOIN words_groups wg ON g.id = wg.group_id
+		WHERE g.parent_id = ?
+		GROUP BY g.id
+		ORDER BY g.name
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	if group.Subgroups, err = scanGroups(rows); err != nil {
+		return nil, err
+	}
+
+	return &group, nil
+}
+
+// GetGroupWords lists the words of a group with a learner's review counts
+func (s *GroupService) GetGroupWords(userID, id, page, perPage int, sortBy, order string, includeDescendants bool) (*models.PaginatedResponse, error) {
+	if err := s.groupExists(id); err != nil {
+		return nil, err
+	}
+
+	tree := groupTree(includeDescendants)
+	var total int
+	err := s.db.QueryRow(tree+`
+		SELECT COUNT(DISTINCT word_id)
+		FROM words_groups
+		WHERE group_id IN (SELECT id FROM tree)
+	`, id).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := (page - 1) * perPage
+	rows, err := s.db.Query(tree+`
+		SELECT w.id, w.japanese, w.romaji, w.english,
+			   COUNT(CASE WHEN wri.correct = 1 THEN 1 END) as correct_count,
+			   COUNT(CASE WHEN wri.correct = 0 THEN 1 END) as wrong_count
+		FROM words w
+		LEFT JOIN word_review_items wri ON w.id = wri.word_id AND wri.user_id = ?
+		WHERE w.id IN (
+			SELECT word_id FROM words_groups WHERE group_id IN (SELECT id FROM tree)
+		)
+		GROUP BY w.id
+		`+orderClause(wordSortColumns, sortBy, order, "w.japanese")+`
+		LIMIT ? OFFSET ?
+	`, id, userID, perPage, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := make([]models.WordWithStats, 0)
+	for rows.Next() {
+		var w models.WordWithStats
+		if err := rows.Scan(&w.ID, &w.Japanese, &w.Romaji, &w.English, &w.CorrectCount, &w.WrongCount); err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return newPaginatedResponse(words, page, perPage, total), nil
+}
+
+// GetGroupStudySessions lists a learner's study sessions of a group
+func (s *GroupService) GetGroupStudySessions(userID, id, page, perPage int, includeDescendants bool) (*models.PaginatedResponse, error) {
+	if err := s.groupExists(id); err != nil {
+		return nil, err
+	}
+
+	groupIDs, err := s.subtreeIDs(id, includeDescendants)
+	if err != nil {
+		return nil, err
+	}
+	where, args := inClause("ss.group_id", groupIDs)
+	return listStudySessions(s.db, "WHERE ss.user_id = ? AND "+where, append([]interface{}{userID}, args...), page, perPage)
+}
+
+// groupScope returns a CTE named scope pairing each key of scopes with the
+// groups whose words and study sessions its stats cover, plus its arguments
+func groupScope(scopes map[int][]int) (string, []interface{}) {
+	values := make([]string, 0, len(scopes))
+	args := make([]interface{}, 0, 2*len(scopes))
+	for key, groupIDs := range scopes {
+		for _, groupID := range groupIDs {
+			values = append(values, "(?, ?)")
+			args = append(args, key, groupID)
+		}
+	}
+	return "WITH scope(key, group_id) AS (VALUES " + strings.Join(values, ", ") + ") ", args
+}
+
+// scopeWords selects the distinct words of each scope in groupScope
+const scopeWords = `
+	SELECT DISTINCT sc.key, wg.word_id
+	FROM scope sc
+	JOIN words_groups wg ON wg.group_id = sc.group_id
+`
+
+// groupStats summarises a learner's progress through the distinct words of
+// groups and the study sessions held on them. scopes maps each key the stats
+// are returned under to the groups they cover, so one call serves a whole
+// page of groups or a group with its subgroups. Recent accuracy covers the
+// last recentSessions sessions.
+func (s *GroupService) groupStats(userID int, scopes map[int][]int, recentSessions int) (map[int]*models.GroupStats, error) {
+	stats := make(map[int]*models.GroupStats, len(scopes))
+	for key := range scopes {
+		stats[key] = &models.GroupStats{RecentSessions: recentSessions}
+	}
+	if len(scopes) == 0 {
+		return stats, nil
+	}
+	scope, scopeArgs := groupScope(scopes)
+
+	rows, err := s.db.Query(scope+`
+		SELECT key, mastery, COUNT(*)
+		FROM (
+			SELECT sw.key, `+masteryColumn+` as mastery
+			FROM (`+scopeWords+`) sw
+			JOIN words w ON w.id = sw.word_id`+learnerJoins+`
+			GROUP BY sw.key, w.id
+		)
+		GROUP BY key, mastery
+	`, append(scopeArgs, userID, userID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, count int
+		var mastery srs.Mastery
+		if err := rows.Scan(&key, &mastery, &count); err != nil {
+			return nil, err
+		}
+		group := stats[key]
+		switch mastery {
+		case srs.MasteryNew:
+			group.Mastery.New = count
+		case srs.MasteryLearning:
+			group.Mastery.Learning = count
+		case srs.MasteryYoung:
+			group.Mastery.Young = count
+		case srs.MasteryMature:
+			group.Mastery.Mature = count
+		case srs.MasteryMastered:
+			group.Mastery.Mastered = count
+		}
+		group.TotalWordCount += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(scope+`
+		SELECT recent.key, COUNT(CASE WHEN wri.correct = 1 THEN 1 END), COUNT(*)
+		FROM (
+			SELECT sc.key, ss.id,
+				ROW_NUMBER() OVER (
+					PARTITION BY sc.key
+					ORDER BY unixepoch(ss.created_at) DESC, ss.id DESC
+				) as recency
+			FROM scope sc
+			JOIN study_sessions ss ON ss.group_id = sc.group_id
+			WHERE ss.user_id = ?
+		) recent
+		JOIN word_review_items wri ON wri.study_session_id = recent.id
+		WHERE recent.recency <= ?
+		GROUP BY recent.key
+	`, append(scopeArgs, userID, recentSessions)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, correct, reviews int
+		if err := rows.Scan(&key, &correct, &reviews); err != nil {
+			return nil, err
+		}
+		recentAccuracy := accuracy(correct, reviews)
+		stats[key].RecentAccuracy = &recentAccuracy
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(scope+`
+		SELECT sc.key, MAX(unixepoch(COALESCE(ss.last_activity_at, ss.created_at)))
+		FROM scope sc
+		JOIN study_sessions ss ON ss.group_id = sc.group_id
+		WHERE ss.user_id = ?
+		GROUP BY sc.key
+	`, append(scopeArgs, userID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key int
+		var lastStudiedAt int64
+		if err := rows.Scan(&key, &lastStudiedAt); err != nil {
+			return nil, err
+		}
+		t := time.Unix(lastStudiedAt, 0).UTC()
+		stats[key].LastStudiedAt = &t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Estimate completion, every word studied, from how many of the group's
+	// words were studied for the first time recently
+	rows, err = s.db.Query(scope+`
+		SELECT sw.key, COUNT(*)
+		FROM (`+scopeWords+`) sw
+		JOIN (
+			SELECT word_id, MIN(unixepoch(created_at)) as first_reviewed_at
+			FROM word_review_items
+			WHERE user_id = ?
+			GROUP BY word_id
+		) started ON started.word_id = sw.word_id
+		WHERE started.first_reviewed_at >= ?
+		GROUP BY sw.key
+	`, append(scopeArgs, userID, time.Now().AddDate(0, 0, -groupPaceDays).Unix())...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	recentlyStarted := make(map[int]int, len(scopes))
+	for rows.Next() {
+		var key, count int
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, err
+		}
+		recentlyStarted[key] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return map[string]interface{}{
-		"id":   id,
-		"name": name,
-		"stats": map[string]interface{}{
-			"total_word_count": 5,
-		},
-	}, nil
-} 
\ No newline at end of file
+	for key, group := range stats {
+		group.WordsStudied = group.TotalWordCount - group.Mastery.New
+		if group.Mastery.New == 0 {
+			days := 0
+			group.EstimatedDaysToCompletion = &days
+		} else if started := recentlyStarted[key]; started > 0 {
+			days := int(math.Ceil(float64(group.Mastery.New) * groupPaceDays / float64(started)))
+			group.EstimatedDaysToCompletion = &days
+		}
+	}
+
+	return stats, nil
+}
+
+// subtreeIDs returns the id of the group and, optionally, its descendants
+func (s *GroupService) subtreeIDs(id int, includeDescendants bool) ([]int, error) {
+	rows, err := s.db.Query(groupTree(includeDescendants)+"SELECT id FROM tree", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var groupID int
+		if err := rows.Scan(&groupID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, groupID)
+	}
+	return ids, rows.Err()
+}
+
+// validateGroup checks a group's name and parent. id is 0 for new groups.
+func (s *GroupService) validateGroup(id int, name string, parentID *int) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", &ValidationError{Field: "name", Message: "must not be empty"}
+	}
+
+	var existing int
+	err := s.db.QueryRow(
+		"SELECT id FROM groups WHERE name = ? COLLATE NOCASE AND id != ?", name, id,
+	).Scan(&existing)
+	if err == nil {
+		return "", ErrDuplicateGroup
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	if parentID == nil {
+		return name, nil
+	}
+	if found, err := exists(s.db, "groups", *parentID); err != nil {
+		return "", err
+	} else if !found {
+		return "", &ValidationError{Field: "parent_id", Message: "refers to a group that does not exist"}
+	}
+	if id != 0 {
+		descendants, err := s.subtreeIDs(id, true)
+		if err != nil {
+			return "", err
+		}
+		for _, descendant := range descendants {
+			if descendant == *parentID {
+				return "", &ValidationError{Field: "parent_id", Message: "cannot be the group itself or one of its subgroups"}
+			}
+		}
+	}
+	return name, nil
+}
+
+func (s *GroupService) CreateGroup(name string, parentID *int) (*models.Group, error) {
+	name, err := s.validateGroup(0, name, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec("INSERT INTO groups (name, parent_id) VALUES (?, ?)", name, parentID)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Group{ID: int(id), Name: name, ParentID: parentID}, nil
+}
+
+// UpdateGroup renames a group and moves it under parentID (nil for top level)
+func (s *GroupService) UpdateGroup(id int, name string, parentID *int) (*models.Group, error) {
+	if err := s.groupExists(id); err != nil {
+		return nil, err
+	}
+	name, err := s.validateGroup(id, name, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec("UPDATE groups SET name = ?, parent_id = ? WHERE id = ?", name, parentID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	group := models.Group{ID: id, Name: name, ParentID: parentID}
+	err = s.db.QueryRow("SELECT COUNT(*) FROM words_groups WHERE group_id = ?", id).Scan(&group.WordCount)
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// DeleteGroup removes a group and its word links; its subgroups move up to
+// its parent. Groups with study sessions cannot be deleted, since that would
+// discard review history, and should be merged into another group instead.
+func (s *GroupService) DeleteGroup(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var parentID sql.NullInt64
+	err = tx.QueryRow("SELECT parent_id FROM groups WHERE id = ?", id).Scan(&parentID)
+	if err == sql.ErrNoRows {
+		return ErrGroupNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var sessions int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM study_sessions WHERE group_id = ?", id).Scan(&sessions); err != nil {
+		return err
+	}
+	if sessions > 0 {
+		return ErrGroupHasSessions
+	}
+
+	if _, err := tx.Exec("UPDATE groups SET parent_id = ? WHERE parent_id = ?", parentID, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM words_groups WHERE group_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM groups WHERE id = ?", id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MergeGroups moves every word link, study session and subgroup of the source
+// group into the target group and deletes the source, all in one transaction.
+// It returns the target group with a learner's progress through it.
+func (s *GroupService) MergeGroups(userID, sourceID, targetID int) (*models.GroupResponse, error) {
+	if sourceID == targetID {
+		return nil, &ValidationError{Field: "target_group_id", Message: "must differ from the group being merged"}
+	}
+	if err := s.groupExists(sourceID); err != nil {
+		return nil, err
+	}
+	if found, err := exists(s.db, "groups", targetID); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, &ValidationError{Field: "target_group_id", Message: "refers to a group that does not exist"}
+	}
+
+	// Merging a group into one of its own subgroups would orphan the subtree
+	descendants, err := s.subtreeIDs(sourceID, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, descendant := range descendants {
+		if descendant == targetID {
+			return nil, &ValidationError{Field: "target_group_id", Message: "cannot be a subgroup of the group being merged"}
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`INSERT INTO words_groups (word_id, group_id)
+		 SELECT DISTINCT word_id, ? FROM words_groups
+		 WHERE group_id = ?
+		   AND word_id NOT IN (SELECT word_id FROM words_groups WHERE group_id = ?)`,
+		`DELETE FROM words_groups WHERE group_id = ?`,
+		`UPDATE study_sessions SET group_id = ? WHERE group_id = ?`,
+		`UPDATE groups SET parent_id = ? WHERE parent_id = ?`,
+		`DELETE FROM groups WHERE id = ?`,
+	}
+	args := [][]interface{}{
+		{targetID, sourceID, targetID},
+		{sourceID},
+		{targetID, sourceID},
+		{targetID, sourceID},
+		{sourceID},
+	}
+	for i, statement := range statements {
+		if _, err := tx.Exec(statement, args[i]...); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetGroup(userID, targetID, false, DefaultRecentSessions)
+}
+
+// AddWordsToGroup links the given words to a group, skipping words that are
+// already members, and returns how many links were created
+func (s *GroupService) AddWordsToGroup(id int, wordIDs []int) (int, error) {
+	if err := s.groupExists(id); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for _, wordID := range wordIDs {
+		var found int
+		err := tx.QueryRow("SELECT 1 FROM words WHERE id = ?", wordID).Scan(&found)
+		if err == sql.ErrNoRows {
+			return 0, &ValidationError{Field: "word_ids", Message: "contains unknown word " + strconv.Itoa(wordID)}
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		result, err := tx.Exec(`
+			INSERT INTO words_groups (word_id, group_id)
+			SELECT ?, ?
+			WHERE NOT EXISTS (
+				SELECT 1 FROM words_groups WHERE word_id = ? AND group_id = ?
+			)
+		`, wordID, id, wordID, id)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		added += int(n)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// RemoveWordsFromGroup unlinks the given words from a group and returns how
+// many links were removed. The words themselves are kept.
+func (s *GroupService) RemoveWordsFromGroup(id int, wordIDs []int) (int, error) {
+	if err := s.groupExists(id); err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	removed := 0
+	for _, wordID := range wordIDs {
+		result, err := tx.Exec("DELETE FROM words_groups WHERE group_id = ? AND word_id = ?", id, wordID)
+		if err != nil {
+			return 0, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		removed += int(n)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
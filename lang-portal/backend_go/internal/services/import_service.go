@@ -0,0 +1,363 @@
+package services
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"strings"
+	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/importer"
+	"github.com/mohawa/lang-portal/backend_go/internal/kana"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+)
+
+// What to do with rows that conflict with an existing word
+const (
+	OnConflictSkip   = "skip"
+	OnConflictUpdate = "update"
+)
+
+// importColumns are the word fields an import can fill in
+var importColumns = []string{"japanese", "romaji", "english"}
+
+// ImportOptions describe how to read an import and where to put it.
+//
+// Mapping renames source fields to word columns, e.g. {"Kanji": "japanese"};
+// sources are column headers, 1-based column numbers for files without a
+// header, or Anki field names. Fields that are not mapped are used when their
+// name matches a column. Words go into the group GroupID or, failing that,
+// the group named GroupName, which is created if needed. Nothing is written
+// unless Commit is set.
+type ImportOptions struct {
+	Format     string
+	Mapping    map[string]string
+	HasHeader  bool
+	GroupID    int
+	GroupName  string
+	OnConflict string
+	Commit     bool
+}
+
+type ImportService struct {
+	db *sql.DB
+}
+
+func NewImportService() *ImportService {
+	return &ImportService{db: database.DB}
+}
+
+// existingWord is a word already in the database that import rows are
+// matched against
+type existingWord struct {
+	id      int
+	romaji  string
+	english string
+}
+
+// defaultMapping fills in where the japanese, romaji and english come from
+// when the mapping does not say: by name when a field is called after the
+// column, otherwise by position for files without a header, or Anki's
+// Front and Back fields
+func defaultMapping(options ImportOptions, record importer.Record) map[string]string {
+	mapping := map[string]string{}
+	mapped := map[string]bool{}
+	for source, column := range options.Mapping {
+		mapping[source] = column
+		mapped[column] = true
+	}
+
+	for field := range record.Fields {
+		for _, column := range importColumns {
+			if !mapped[column] && strings.EqualFold(field, column) {
+				if _, ok := mapping[field]; !ok {
+					mapping[field] = column
+					mapped[column] = true
+				}
+			}
+		}
+	}
+
+	var fallback map[string]string
+	switch {
+	case options.Format == importer.FormatApkg:
+		fallback = map[string]string{"Front": "japanese", "Back": "english"}
+	case !options.HasHeader:
+		fallback = map[string]string{"1": "japanese", "2": "romaji", "3": "english"}
+	}
+	for source, column := range fallback {
+		if _, ok := mapping[source]; !ok && !mapped[column] {
+			mapping[source] = column
+		}
+	}
+	return mapping
+}
+
+// ImportWords previews or commits an import. Every row is classified as new,
+// a duplicate of an existing word or an earlier row, a conflict with one, or
+// an error. Committing creates the new words, updates conflicting words when
+// OnConflict is update, and links every created, duplicate or updated word to
+// the target group, all in one transaction. Rows with errors are reported
+// and skipped.
+func (s *ImportService) ImportWords(data []byte, options ImportOptions) (*models.ImportResult, error) {
+	if options.Format == "" {
+		return nil, &ValidationError{Field: "format", Message: "must be given when it cannot be told from the file name"}
+	}
+	if options.Format != importer.FormatCSV && options.Format != importer.FormatTSV && options.Format != importer.FormatApkg {
+		return nil, &ValidationError{Field: "format", Message: "must be one of csv, tsv or apkg"}
+	}
+	if options.OnConflict == "" {
+		options.OnConflict = OnConflictSkip
+	}
+	if options.OnConflict != OnConflictSkip && options.OnConflict != OnConflictUpdate {
+		return nil, &ValidationError{Field: "on_conflict", Message: "must be skip or update"}
+	}
+	options.GroupName = strings.TrimSpace(options.GroupName)
+	if options.GroupID == 0 && options.GroupName == "" {
+		return nil, &ValidationError{Field: "group_id", Message: "or group must name the group to import into"}
+	}
+	for source, column := range options.Mapping {
+		known := false
+		for _, importColumn := range importColumns {
+			known = known || column == importColumn
+		}
+		if !known {
+			return nil, &ValidationError{Field: "mapping", Message: "maps " + strconv.Quote(source) + " to unknown column " + strconv.Quote(column)}
+		}
+	}
+
+	records, err := importer.Read(data, options.Format, options.HasHeader)
+	if err != nil {
+		return nil, &ValidationError{Field: "file", Message: "could not be read: " + err.Error()}
+	}
+	if len(records) == 0 {
+		return nil, &ValidationError{Field: "file", Message: "contains no rows"}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &models.ImportResult{
+		Format:    options.Format,
+		GroupName: options.GroupName,
+		Rows:      make([]models.ImportRow, 0, len(records)),
+	}
+	if err := resolveImportGroup(tx, options, result); err != nil {
+		return nil, err
+	}
+
+	existing, err := loadExistingWords(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// earlier maps a word's japanese and reading key to the first row with them
+	earlier := map[string]int{}
+	for _, record := range records {
+		row := classifyImportRow(record, defaultMapping(options, record), existing, earlier, result.Rows)
+		switch row.Status {
+		case models.ImportNew:
+			result.Summary.New++
+		case models.ImportDuplicate:
+			result.Summary.Duplicate++
+		case models.ImportConflict:
+			result.Summary.Conflict++
+		case models.ImportError:
+			result.Summary.Error++
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	result.Summary.Total = len(result.Rows)
+
+	if !options.Commit {
+		return result, nil
+	}
+
+	for i := range result.Rows {
+		row := &result.Rows[i]
+		switch {
+		case row.Status == models.ImportNew:
+			inserted, err := tx.Exec(
+				"INSERT INTO words (japanese, romaji, english) VALUES (?, ?, ?)",
+				row.Japanese, row.Romaji, row.English,
+			)
+			if err != nil {
+				return nil, err
+			}
+			id, err := inserted.LastInsertId()
+			if err != nil {
+				return nil, err
+			}
+			wordID := int(id)
+			row.WordID = &wordID
+			row.Action = models.ImportCreated
+		case row.Status == models.ImportDuplicate && row.WordID != nil:
+			row.Action = models.ImportLinked
+		case row.Status == models.ImportDuplicate:
+			// A repeat of an earlier row shares the word that row created
+			if first := earlier[importKey(row.Japanese, row.Romaji)]; result.Rows[first].WordID != nil {
+				row.WordID = result.Rows[first].WordID
+				row.Action = models.ImportLinked
+			} else {
+				row.Action = models.ImportSkipped
+			}
+		case row.Status == models.ImportConflict && row.WordID != nil && options.OnConflict == OnConflictUpdate:
+			_, err := tx.Exec("UPDATE words SET romaji = ?, english = ? WHERE id = ?", row.Romaji, row.English, *row.WordID)
+			if err != nil {
+				return nil, err
+			}
+			row.Action = models.ImportUpdated
+		default:
+			row.Action = models.ImportSkipped
+		}
+
+		if row.Action == models.ImportSkipped {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT INTO words_groups (word_id, group_id)
+			SELECT ?, ?
+			WHERE NOT EXISTS (
+				SELECT 1 FROM words_groups WHERE word_id = ? AND group_id = ?
+			)
+		`, *row.WordID, *result.GroupID, *row.WordID, *result.GroupID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	result.Committed = true
+	return result, nil
+}
+
+// resolveImportGroup finds the target group, creating a named group that
+// does not exist yet when the import is committed
+func resolveImportGroup(tx *sql.Tx, options ImportOptions, result *models.ImportResult) error {
+	var id int
+	var name string
+	if options.GroupID != 0 {
+		err := tx.QueryRow("SELECT id, name FROM groups WHERE id = ?", options.GroupID).Scan(&id, &name)
+		if err == sql.ErrNoRows {
+			return ErrGroupNotFound
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		err := tx.QueryRow("SELECT id, name FROM groups WHERE name = ?", options.GroupName).Scan(&id, &name)
+		if err == sql.ErrNoRows {
+			if !options.Commit {
+				return nil
+			}
+			inserted, err := tx.Exec("INSERT INTO groups (name) VALUES (?)", options.GroupName)
+			if err != nil {
+				return err
+			}
+			newID, err := inserted.LastInsertId()
+			if err != nil {
+				return err
+			}
+			id, name = int(newID), options.GroupName
+		} else if err != nil {
+			return err
+		}
+	}
+	result.GroupID = &id
+	result.GroupName = name
+	return nil
+}
+
+// loadExistingWords indexes every word by its japanese
+func loadExistingWords(tx *sql.Tx) (map[string][]existingWord, error) {
+	rows, err := tx.Query("SELECT id, japanese, romaji, english FROM words ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := map[string][]existingWord{}
+	for rows.Next() {
+		var word existingWord
+		var japanese string
+		if err := rows.Scan(&word.id, &japanese, &word.romaji, &word.english); err != nil {
+			return nil, err
+		}
+		words[japanese] = append(words[japanese], word)
+	}
+	return words, rows.Err()
+}
+
+// importKey identifies a word by its japanese and reading, ignoring how the
+// reading is romanised, as the duplicate check on create does
+func importKey(japanese, romaji string) string {
+	return japanese + "\x00" + kana.ReadingKey(romaji)
+}
+
+// classifyImportRow maps a record onto a word, validates it the way creating
+// a word does and compares it against existing words and earlier rows
+func classifyImportRow(record importer.Record, mapping map[string]string, existing map[string][]existingWord, earlier map[string]int, previous []models.ImportRow) models.ImportRow {
+	var word models.Word
+	sources := make([]string, 0, len(record.Fields))
+	for source := range record.Fields {
+		sources = append(sources, source)
+	}
+	// Visit fields in a fixed order so that a column mapped twice is stable
+	sort.Strings(sources)
+	for _, source := range sources {
+		value := record.Fields[source]
+		switch mapping[source] {
+		case "japanese":
+			word.Japanese = value
+		case "romaji":
+			word.Romaji = value
+		case "english":
+			word.English = value
+		}
+	}
+
+	row := models.ImportRow{Row: record.Row}
+	err := validateWord(&word)
+	row.Japanese, row.Romaji, row.English = word.Japanese, word.Romaji, word.English
+	if err != nil {
+		row.Status = models.ImportError
+		row.Message = err.Error()
+		return row
+	}
+
+	key := importKey(word.Japanese, word.Romaji)
+	if first, ok := earlier[key]; ok {
+		if strings.EqualFold(previous[first].English, word.English) {
+			row.Status = models.ImportDuplicate
+			row.Message = "repeats row " + strconv.Itoa(previous[first].Row)
+		} else {
+			row.Status = models.ImportConflict
+			row.Message = "conflicts with row " + strconv.Itoa(previous[first].Row)
+		}
+		return row
+	}
+	earlier[key] = len(previous)
+
+	readingKey := kana.ReadingKey(word.Romaji)
+	for _, match := range existing[word.Japanese] {
+		if kana.ReadingKey(match.romaji) != readingKey {
+			continue
+		}
+		id := match.id
+		row.WordID = &id
+		if strings.EqualFold(match.english, word.English) {
+			row.Status = models.ImportDuplicate
+		} else {
+			row.Status = models.ImportConflict
+			row.Message = "existing word means " + strconv.Quote(match.english)
+		}
+		return row
+	}
+
+	row.Status = models.ImportNew
+	return row
+}
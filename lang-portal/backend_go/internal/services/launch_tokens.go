@@ -0,0 +1,125 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"strings"
+	"time"
+	"github.com/mohawa/lang-portal/backend_go/internal/launchtoken"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+)
+
+// Launch tokens are signed with a random secret unless ConfigureLaunchTokens
+// sets one, so by default they only last until the process exits
+var (
+	launchSecret   = rand.Text()
+	launchTokenTTL = 2 * time.Hour
+)
+
+// ConfigureLaunchTokens sets the secret launch tokens are signed with and how
+// long a token stays valid after it is issued. An empty secret keeps the
+// random one.
+func ConfigureLaunchTokens(secret string, ttl time.Duration) {
+	if secret != "" {
+		launchSecret = secret
+	}
+	launchTokenTTL = ttl
+}
+
+// issueLaunchToken signs a token letting the app launched for a learner's
+// session post results into it
+func issueLaunchToken(sessionID, userID, groupID, studyActivityID int) (string, time.Time, error) {
+	now := time.Now()
+	claims := launchtoken.Claims{
+		StudySessionID:  sessionID,
+		UserID:          userID,
+		GroupID:         groupID,
+		StudyActivityID: studyActivityID,
+		IssuedAt:        now.Unix(),
+		ExpiresAt:       now.Add(launchTokenTTL).Unix(),
+	}
+	token, err := launchtoken.Sign([]byte(launchSecret), claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, claims.Expires(), nil
+}
+
+// withLaunchToken adds a launch token to a launch URL: in place of its
+// {launch_token} placeholder, or else as the launch_token query parameter
+func withLaunchToken(launchURL, token string) string {
+	if strings.Contains(launchURL, "{launch_token}") {
+		return strings.ReplaceAll(launchURL, "{launch_token}", token)
+	}
+	fragment := ""
+	if i := strings.Index(launchURL, "#"); i >= 0 {
+		launchURL, fragment = launchURL[:i], launchURL[i:]
+	}
+	separator := "?"
+	if strings.Contains(launchURL, "?") {
+		separator = "&"
+	}
+	return launchURL + separator + "launch_token=" + token + fragment
+}
+
+// ParseLaunchToken checks a launch token's signature and expiry
+func ParseLaunchToken(token string) (*launchtoken.Claims, error) {
+	claims, err := launchtoken.Verify([]byte(launchSecret), token, time.Now())
+	if err != nil {
+		return nil, ErrLaunchTokenInvalid
+	}
+	return claims, nil
+}
+
+// authorizeSession checks that a launch token allows posting results into a
+// session. The session must still belong to the learner and group the token
+// was issued for, so moving it to another group revokes its token.
+func authorizeSession(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, launch *launchtoken.Claims, sessionID int) error {
+	if launch == nil {
+		return ErrLaunchTokenRequired
+	}
+	if launch.StudySessionID != sessionID {
+		return ErrLaunchTokenScope
+	}
+	var userID, groupID int
+	err := q.QueryRow("SELECT user_id, group_id FROM study_sessions WHERE id = ?", sessionID).Scan(&userID, &groupID)
+	if err == sql.ErrNoRows {
+		return ErrStudySessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if userID != launch.UserID || groupID != launch.GroupID {
+		return ErrLaunchTokenInvalid
+	}
+	return nil
+}
+
+// VerifyLaunchToken checks that token is a valid launch token for the study
+// session. An empty token returns ErrLaunchTokenRequired.
+func (s *StudyService) VerifyLaunchToken(token string, sessionID int) (*launchtoken.Claims, error) {
+	if token == "" {
+		return nil, ErrLaunchTokenRequired
+	}
+	claims, err := ParseLaunchToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeSession(s.db, claims, sessionID); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// RenewLaunchToken issues a fresh token for the session a verified token was
+// issued for, so apps can keep posting results for as long as the session
+// stays active
+func (s *StudyService) RenewLaunchToken(claims *launchtoken.Claims) (*models.LaunchToken, error) {
+	token, expiresAt, err := issueLaunchToken(claims.StudySessionID, claims.UserID, claims.GroupID, claims.StudyActivityID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LaunchToken{Token: token, ExpiresAt: expiresAt}, nil
+}
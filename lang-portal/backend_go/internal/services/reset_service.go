@@ -3,6 +3,7 @@ package services
 import (
 	"database/sql"
 	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
 )
 
 type ResetService struct {
@@ -13,63 +14,63 @@ func NewResetService() *ResetService {
 	return &ResetService{db: database.DB}
 }
 
-func (s *ResetService) ResetHistory() error {
+// ResetHistory deletes a learner's study history: their xAPI statements,
+// review schedules, reviews and study sessions
+func (s *ResetService) ResetHistory(userID int) (*models.ResetResult, error) {
+	return s.deleteHistory(" WHERE user_id = ?", userID)
+}
+
+// FullReset deletes the study history of every learner. Accounts, words,
+// groups and study activities are kept, so the portal stays usable; it no
+// longer empties every table the way the single-user system reset did.
+func (s *ResetService) FullReset() (*models.ResetResult, error) {
+	return s.deleteHistory("")
+}
+
+// deleteHistory deletes the study history rows matching where in one
+// transaction and counts what went
+func (s *ResetService) deleteHistory(where string, args ...interface{}) (*models.ResetResult, error) {
 	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback()
 
-	// Clear study history tables
-	_, err = tx.Exec("DELETE FROM word_review_items")
-	if err != nil {
-		return err
-	}
+	var result models.ResetResult
 
-	_, err = tx.Exec("DELETE FROM study_sessions")
+	// Statements record the reviews and sessions deleted below
+	deleted, err := tx.Exec("DELETE FROM xapi_statements"+where, args...)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if result.StatementsDeleted, err = deleted.RowsAffected(); err != nil {
+		return nil, err
 	}
 
-	_, err = tx.Exec("DELETE FROM study_activities")
+	// Schedules are derived from the review history
+	_, err = tx.Exec("DELETE FROM word_schedules"+where, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return tx.Commit()
-}
-
-func (s *ResetService) FullReset() error {
-	tx, err := s.db.Begin()
+	deleted, err = tx.Exec("DELETE FROM word_review_items"+where, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer tx.Rollback()
-
-	// Clear all tables in correct order to respect foreign keys
-	tables := []string{
-		"word_review_items",
-		"study_sessions",
-		"study_activities",
-		"words_groups",
-		"words",
-		"groups",
+	if result.ReviewsDeleted, err = deleted.RowsAffected(); err != nil {
+		return nil, err
 	}
 
-	for _, table := range tables {
-		_, err = tx.Exec("DELETE FROM " + table)
-		if err != nil {
-			return err
-		}
+	deleted, err = tx.Exec("DELETE FROM study_sessions"+where, args...)
+	if err != nil {
+		return nil, err
 	}
-
-	// Reset auto-increment counters
-	for _, table := range tables {
-		_, err = tx.Exec("DELETE FROM sqlite_sequence WHERE name = ?", table)
-		if err != nil {
-			return err
-		}
+	if result.StudySessionsDeleted, err = deleted.RowsAffected(); err != nil {
+		return nil, err
 	}
 
-	return tx.Commit()
-} 
\ No newline at end of file
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,360 @@
+package services
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"unicode"
+	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/kana"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+	"github.com/mohawa/lang-portal/backend_go/internal/srs"
+)
+
+const (
+	highlightStart = "<mark>"
+	highlightEnd   = "</mark>"
+)
+
+// matchTiers orders search results by how they matched
+var matchTiers = map[string]int{
+	models.MatchExact:    0,
+	models.MatchRomaji:   1,
+	models.MatchJapanese: 2,
+	models.MatchEnglish:  3,
+	models.MatchFuzzy:    4,
+}
+
+type SearchService struct {
+	db *sql.DB
+}
+
+func NewSearchService() *SearchService {
+	return &SearchService{db: database.DB}
+}
+
+type searchHit struct {
+	result *models.WordSearchResult
+	rank   float64
+}
+
+// SearchWords finds words whose romaji starts with the query, whose japanese
+// contains it or whose english matches it after stemming. Romaji and kana
+// queries are converted into each other so either finds the word, and also
+// match readings within a small edit distance to tolerate typos. Review
+// counts and mastery are the learner's; a non-empty mastery keeps only the
+// words at that level. Results are ordered by how well they match unless
+// sortBy names a word column.
+func (s *SearchService) SearchWords(userID int, query string, page, perPage int, sortBy, order string, mastery srs.Mastery) (*models.PaginatedResponse, error) {
+	query = strings.TrimSpace(kana.NormalizeWidth(query))
+	hits := map[int]*searchHit{}
+	record := func(id int, match string, rank float64) *searchHit {
+		hit, ok := hits[id]
+		if !ok || matchTiers[match] < matchTiers[hit.result.Match] {
+			if !ok {
+				hit = &searchHit{result: &models.WordSearchResult{}}
+				hits[id] = hit
+			}
+			hit.result.Match = match
+			hit.rank = rank
+		}
+		return hit
+	}
+
+	// Kana queries are also looked up by their romaji reading
+	romajiQuery := query
+	if kana.IsKana(query) {
+		romajiQuery = kana.ToRomaji(query)
+	}
+	if expr := ftsQuery(romajiQuery, true); expr != "" {
+		err := s.matchIndex("words_romaji_fts", expr, func(id int, highlight string, rank float64) {
+			record(id, models.MatchRomaji, rank).result.Highlight.Romaji = highlight
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if expr := ftsQuery(query, false); expr != "" {
+		err := s.matchIndex("words_english_fts", expr, func(id int, highlight string, rank float64) {
+			record(id, models.MatchEnglish, rank).result.Highlight.English = highlight
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if forms := kanaForms(query); len(forms) > 0 {
+		// The trigram index serves LIKE patterns of three or more characters;
+		// shorter ones fall back to scanning the index
+		conditions := make([]string, len(forms))
+		args := make([]interface{}, 0, len(forms)+1)
+		for i, form := range forms {
+			conditions[i] = "japanese LIKE ?"
+			args = append(args, "%"+strings.NewReplacer("%", "", "_", "").Replace(form)+"%")
+		}
+		rows, err := s.db.Query(`
+			SELECT rowid FROM words_japanese_fts
+			WHERE `+strings.Join(conditions, " OR "), args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				return nil, err
+			}
+			record(id, models.MatchJapanese, 0)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.matchTypos(query, hits, record); err != nil {
+		return nil, err
+	}
+
+	ordered, err := s.loadWords(userID, hits, sortBy, order, mastery)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*searchHit, 0, len(ordered))
+	for _, id := range ordered {
+		hit := hits[id]
+		word := &hit.result.WordWithStats
+		if word.Japanese == query || strings.EqualFold(word.English, query) ||
+			kana.SameReading(word.Romaji, query) || (kana.IsKana(word.Japanese) && kana.SameReading(word.Japanese, query)) {
+			hit.result.Match = models.MatchExact
+		}
+
+		highlight := &hit.result.Highlight
+		if highlight.Japanese == "" {
+			highlight.Japanese = markSubstring(word.Japanese, query)
+		}
+		if highlight.Romaji == "" {
+			highlight.Romaji = word.Romaji
+			if hit.result.Match == models.MatchFuzzy {
+				highlight.Romaji = highlightStart + word.Romaji + highlightEnd
+			}
+		}
+		if highlight.English == "" {
+			highlight.English = word.English
+		}
+		results = append(results, hit)
+	}
+
+	// Without a sort column, results are ordered by how well they matched;
+	// otherwise they keep the order loadWords sorted them in
+	if _, ok := wordSortColumns[sortBy]; !ok {
+		sort.Slice(results, func(i, j int) bool {
+			a, b := results[i], results[j]
+			if matchTiers[a.result.Match] != matchTiers[b.result.Match] {
+				return matchTiers[a.result.Match] < matchTiers[b.result.Match]
+			}
+			if a.rank != b.rank {
+				return a.rank < b.rank
+			}
+			return a.result.ID < b.result.ID
+		})
+	}
+
+	items := make([]models.WordSearchResult, 0, perPage)
+	for i := (page - 1) * perPage; i >= 0 && i < len(results) && len(items) < perPage; i++ {
+		items = append(items, *results[i].result)
+	}
+	return newPaginatedResponse(items, page, perPage, len(results)), nil
+}
+
+// matchIndex runs a MATCH query against a single column FTS5 index and
+// reports each hit with its highlighted text and bm25 rank
+func (s *SearchService) matchIndex(table, expr string, hit func(id int, highlight string, rank float64)) error {
+	rows, err := s.db.Query(`
+		SELECT rowid, highlight(`+table+`, 0, ?, ?), bm25(`+table+`)
+		FROM `+table+`
+		WHERE `+table+` MATCH ?
+		ORDER BY rank
+	`, highlightStart, highlightEnd, expr)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var highlight string
+		var rank float64
+		if err := rows.Scan(&id, &highlight, &rank); err != nil {
+			return err
+		}
+		hit(id, highlight, rank)
+	}
+	return rows.Err()
+}
+
+// matchTypos adds words whose reading is within a small edit distance of a
+// romaji or kana query, comparing both the whole reading and its prefix so
+// that partially typed words still match
+func (s *SearchService) matchTypos(query string, hits map[int]*searchHit, record func(int, string, float64) *searchHit) error {
+	if !isRomajiQuery(query) && !kana.IsKana(query) {
+		return nil
+	}
+	key := []rune(kana.ReadingKey(query))
+	if len(key) < 4 {
+		return nil
+	}
+	maxDistance := 1
+	if len(key) > 6 {
+		maxDistance = 2
+	}
+
+	rows, err := s.db.Query("SELECT id, romaji FROM words")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var romaji string
+		if err := rows.Scan(&id, &romaji); err != nil {
+			return err
+		}
+		if _, ok := hits[id]; ok {
+			continue
+		}
+
+		candidate := []rune(kana.ReadingKey(romaji))
+		distance := editDistance(key, candidate)
+		if len(candidate) > len(key) {
+			if prefix := editDistance(key, candidate[:len(key)]); prefix < distance {
+				distance = prefix
+			}
+		}
+		if distance <= maxDistance {
+			record(id, models.MatchFuzzy, float64(distance))
+		}
+	}
+	return rows.Err()
+}
+
+// loadWords fills in the word fields and a learner's review counts and
+// mastery of every hit. It returns the ids of the hits at the given mastery,
+// or all of them when it is empty, sorted by sortBy when that names a word
+// column.
+func (s *SearchService) loadWords(userID int, hits map[int]*searchHit, sortBy, order string, mastery srs.Mastery) ([]int, error) {
+	if len(hits) == 0 {
+		return nil, nil
+	}
+	ids := make([]int, 0, len(hits))
+	for id := range hits {
+		ids = append(ids, id)
+	}
+
+	where, args := inClause("w.id", ids)
+	args = append([]interface{}{userID, userID}, args...)
+	having := ""
+	if mastery != "" {
+		having = "HAVING mastery = ?"
+		args = append(args, mastery)
+	}
+	rows, err := s.db.Query(`
+		SELECT w.id, w.japanese, w.romaji, w.english,
+			   COUNT(CASE WHEN wri.correct = 1 THEN 1 END) as correct_count,
+			   COUNT(CASE WHEN wri.correct = 0 THEN 1 END) as wrong_count,
+			   `+masteryColumn+` as mastery,
+			   `+leechColumn+` as leech
+		FROM words w`+learnerJoins+`
+		WHERE `+where+`
+		GROUP BY w.id
+		`+having+`
+		`+orderClause(wordSortColumns, sortBy, order, "w.id")+`, w.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ordered := make([]int, 0, len(ids))
+	for rows.Next() {
+		var w models.WordWithStats
+		if err := rows.Scan(&w.ID, &w.Japanese, &w.Romaji, &w.English, &w.CorrectCount, &w.WrongCount, &w.Mastery, &w.Leech); err != nil {
+			return nil, err
+		}
+		hits[w.ID].result.WordWithStats = w
+		ordered = append(ordered, w.ID)
+	}
+	return ordered, rows.Err()
+}
+
+// ftsQuery turns user input into an FTS5 query of quoted terms so that
+// punctuation in the input cannot be read as query syntax
+func ftsQuery(query string, prefix bool) string {
+	terms := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, term := range terms {
+		terms[i] = `"` + term + `"`
+		if prefix {
+			terms[i] += "*"
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// isRomajiQuery reports whether the query is plain latin text
+func isRomajiQuery(query string) bool {
+	for _, r := range query {
+		if !unicode.In(r, unicode.Latin) && !unicode.IsSpace(r) && r != '-' && r != '\'' {
+			return false
+		}
+	}
+	return true
+}
+
+// isJapaneseQuery reports whether the query contains kana or kanji
+func isJapaneseQuery(query string) bool {
+	for _, r := range query {
+		if kana.IsHiragana(r) || kana.IsKatakana(r) || unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// kanaForms lists the strings to look for in the japanese field: the query
+// itself in hiragana and katakana, or for romaji queries the kana it spells
+func kanaForms(query string) []string {
+	source := ""
+	switch {
+	case isJapaneseQuery(query):
+		source = query
+	case isRomajiQuery(query):
+		converted := kana.FromRomaji(strings.ReplaceAll(query, " ", ""))
+		if kana.IsKana(converted) {
+			source = converted
+		}
+	}
+	if source == "" {
+		return nil
+	}
+
+	forms := []string{source}
+	for _, form := range []string{kana.ToHiragana(source), kana.ToKatakana(source)} {
+		if form != forms[0] && (len(forms) == 1 || form != forms[1]) {
+			forms = append(forms, form)
+		}
+	}
+	return forms
+}
+
+func markSubstring(text, query string) string {
+	for _, form := range kanaForms(query) {
+		if strings.Contains(text, form) {
+			return strings.ReplaceAll(text, form, highlightStart+form+highlightEnd)
+		}
+	}
+	return text
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// StartSessionSweeper closes study sessions that have been idle for longer
+// than timeout, once at startup and then every interval, until the returned
+// stop function is called
+func StartSessionSweeper(interval, timeout time.Duration) (stop func()) {
+	done := make(chan struct{})
+	sweep := func() {
+		n, err := NewStudyService().CloseIdleSessions(timeout)
+		if err != nil {
+			log.Printf("Error closing idle study sessions: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("Closed %d idle study sessions", n)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		sweep()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				sweep()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
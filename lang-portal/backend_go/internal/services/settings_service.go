@@ -0,0 +1,89 @@
+package services
+
+import (
+	"database/sql"
+	"time"
+	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+)
+
+type SettingsService struct {
+	db *sql.DB
+}
+
+func NewSettingsService() *SettingsService {
+	return &SettingsService{db: database.DB}
+}
+
+// SettingsUpdate holds the settings to change; nil fields are left as they are
+type SettingsUpdate struct {
+	Timezone         *string
+	DailyGoalReviews *int
+	DailyGoalMinutes *int
+	StreakFreezes    *int
+}
+
+// GetSettings returns a learner's settings, or the defaults for learners who
+// have not changed any
+func (s *SettingsService) GetSettings(userID int) (*models.Settings, error) {
+	var settings models.Settings
+	err := s.db.QueryRow(`
+		SELECT timezone, daily_goal_reviews, daily_goal_minutes, streak_freezes
+		FROM settings
+		WHERE user_id = ?
+	`, userID).Scan(&settings.Timezone, &settings.DailyGoalReviews, &settings.DailyGoalMinutes, &settings.StreakFreezes)
+	if err == sql.ErrNoRows {
+		return &models.Settings{Timezone: "UTC"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (s *SettingsService) UpdateSettings(userID int, update SettingsUpdate) (*models.Settings, error) {
+	settings, err := s.GetSettings(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Timezone != nil {
+		if _, err := time.LoadLocation(*update.Timezone); err != nil || *update.Timezone == "" || *update.Timezone == "Local" {
+			return nil, &ValidationError{Field: "timezone", Message: "must be an IANA time zone such as Asia/Tokyo"}
+		}
+		settings.Timezone = *update.Timezone
+	}
+	for _, field := range []struct {
+		name  string
+		value *int
+		dest  *int
+	}{
+		{"daily_goal_reviews", update.DailyGoalReviews, &settings.DailyGoalReviews},
+		{"daily_goal_minutes", update.DailyGoalMinutes, &settings.DailyGoalMinutes},
+		{"streak_freezes", update.StreakFreezes, &settings.StreakFreezes},
+	} {
+		if field.value == nil {
+			continue
+		}
+		if *field.value < 0 {
+			return nil, &ValidationError{Field: field.name, Message: "must not be negative"}
+		}
+		*field.dest = *field.value
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO settings (user_id, timezone, daily_goal_reviews, daily_goal_minutes, streak_freezes, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			timezone = excluded.timezone,
+			daily_goal_reviews = excluded.daily_goal_reviews,
+			daily_goal_minutes = excluded.daily_goal_minutes,
+			streak_freezes = excluded.streak_freezes,
+			updated_at = excluded.updated_at
+	`, userID, settings.Timezone, settings.DailyGoalReviews, settings.DailyGoalMinutes, settings.StreakFreezes, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"database/sql"
+	"time"
+	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+	"github.com/mohawa/lang-portal/backend_go/internal/srs"
+)
+
+type SRSService struct {
+	db *sql.DB
+}
+
+func NewSRSService() *SRSService {
+	return &SRSService{db: database.DB}
+}
+
+// updateSchedule advances a learner's SM-2 schedule of a word after a
+// review. It runs inside the caller's transaction so the review and the
+// schedule are always written together.
+func updateSchedule(tx *sql.Tx, userID, wordID int, quality srs.Quality, now time.Time) error {
+	state := srs.NewState()
+	err := tx.QueryRow(`
+		SELECT ease_factor, interval_days, repetitions, lapses, due_at
+		FROM word_schedules
+		WHERE user_id = ? AND word_id = ?
+	`, userID, wordID).Scan(&state.EaseFactor, &state.IntervalDays, &state.Repetitions, &state.Lapses, &state.DueAt)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	now = now.UTC()
+	state = srs.Review(state, quality, now)
+
+	_, err = tx.Exec(`
+		INSERT INTO word_schedules
+			(user_id, word_id, ease_factor, interval_days, repetitions, lapses, due_at, last_reviewed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, word_id) DO UPDATE SET
+			ease_factor = excluded.ease_factor,
+			interval_days = excluded.interval_days,
+			repetitions = excluded.repetitions,
+			lapses = excluded.lapses,
+			due_at = excluded.due_at,
+			last_reviewed_at = excluded.last_reviewed_at
+	`, userID, wordID, state.EaseFactor, state.IntervalDays, state.Repetitions, state.Lapses, state.DueAt, now)
+	return err
+}
+
+// GetReviewQueue returns up to limit words for a learner to study, most urgent first.
+// Words that are due come first, ordered by how overdue they are relative to
+// their interval; any remaining slots are filled with words never reviewed.
+// A groupID of 0 selects words from every group.
+func (s *SRSService) GetReviewQueue(userID, groupID, limit int) ([]models.ReviewQueueItem, error) {
+	if groupID != 0 {
+		if found, err := exists(s.db, "groups", groupID); err != nil {
+			return nil, err
+		} else if !found {
+			return nil, ErrGroupNotFound
+		}
+	}
+
+	now := time.Now().UTC()
+	rows, err := s.db.Query(`
+		SELECT w.id, w.japanese, w.romaji, w.english,
+			   ws.ease_factor, ws.interval_days, ws.repetitions, ws.lapses, ws.due_at
+		FROM word_schedules ws
+		JOIN words w ON w.id = ws.word_id
+		WHERE ws.user_id = ? AND ws.due_at <= ?
+		  AND (? = 0 OR w.id IN (SELECT word_id FROM words_groups WHERE group_id = ?))
+		ORDER BY (julianday(?) - julianday(ws.due_at)) / MAX(ws.interval_days, 1) DESC, w.id
+		LIMIT ?
+	`, userID, now, groupID, groupID, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]models.ReviewQueueItem, 0, limit)
+	for rows.Next() {
+		var item models.ReviewQueueItem
+		var state srs.State
+		if err := rows.Scan(
+			&item.ID, &item.Japanese, &item.Romaji, &item.English,
+			&state.EaseFactor, &state.IntervalDays, &state.Repetitions, &state.Lapses, &state.DueAt,
+		); err != nil {
+			return nil, err
+		}
+		item.DueAt = &state.DueAt
+		item.EaseFactor = state.EaseFactor
+		item.IntervalDays = state.IntervalDays
+		item.Repetitions = state.Repetitions
+		item.Lapses = state.Lapses
+		item.Urgency = srs.Urgency(state, now)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(items) >= limit {
+		return items, nil
+	}
+
+	newRows, err := s.db.Query(`
+		SELECT w.id, w.japanese, w.romaji, w.english
+		FROM words w
+		LEFT JOIN word_schedules ws ON ws.word_id = w.id AND ws.user_id = ?
+		WHERE ws.word_id IS NULL
+		  AND (? = 0 OR w.id IN (SELECT word_id FROM words_groups WHERE group_id = ?))
+		ORDER BY w.id
+		LIMIT ?
+	`, userID, groupID, groupID, limit-len(items))
+	if err != nil {
+		return nil, err
+	}
+	defer newRows.Close()
+
+	for newRows.Next() {
+		item := models.ReviewQueueItem{IsNew: true, EaseFactor: srs.DefaultEaseFactor}
+		if err := newRows.Scan(&item.ID, &item.Japanese, &item.Romaji, &item.English); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, newRows.Err()
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+)
+
+// studyDay totals the study done on one calendar day
+type studyDay struct {
+	reviews int
+	seconds float64
+}
+
+// streakSummary describes the learner's streaks and today's goal progress
+type streakSummary struct {
+	current     int
+	longest     int
+	freezesUsed int
+	today       models.DailyGoalProgress
+}
+
+// settingsLocation returns the learner's timezone, falling back to UTC when
+// the stored zone is unknown to this system
+func settingsLocation(settings *models.Settings) *time.Location {
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// civilDate truncates t to its calendar date in loc, expressed as midnight UTC
+// so that days can be counted without daylight saving getting in the way
+func civilDate(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// goalMet reports whether a day's study meets the daily goal. With no goal
+// set a single review is enough; with both set either one counts.
+func goalMet(day *studyDay, settings *models.Settings) bool {
+	if day == nil {
+		return false
+	}
+	if settings.DailyGoalReviews == 0 && settings.DailyGoalMinutes == 0 {
+		return day.reviews > 0
+	}
+	return (settings.DailyGoalReviews > 0 && day.reviews >= settings.DailyGoalReviews) ||
+		(settings.DailyGoalMinutes > 0 && day.seconds >= float64(settings.DailyGoalMinutes)*60)
+}
+
+// loadStudyDays totals a learner's reviews and session time per calendar day
+// in loc. Session time is credited to the day the session started.
+func loadStudyDays(db *sql.DB, userID int, loc *time.Location) (map[time.Time]*studyDay, error) {
+	days := map[time.Time]*studyDay{}
+	day := func(t time.Time) *studyDay {
+		date := civilDate(t, loc)
+		if days[date] == nil {
+			days[date] = &studyDay{}
+		}
+		return days[date]
+	}
+
+	rows, err := db.Query("SELECT created_at FROM word_review_items WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var createdAt time.Time
+		if err := rows.Scan(&createdAt); err != nil {
+			return nil, err
+		}
+		day(createdAt).reviews++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sessions, err := db.Query(`
+		SELECT created_at, COALESCE(ended_at, last_activity_at, created_at)
+		FROM study_sessions
+		WHERE user_id = ?
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer sessions.Close()
+	for sessions.Next() {
+		var startedAt time.Time
+		var endedAt string
+		if err := sessions.Scan(&startedAt, &endedAt); err != nil {
+			return nil, err
+		}
+		if seconds := parseTime(endedAt).Sub(startedAt).Seconds(); seconds > 0 {
+			day(startedAt).seconds += seconds
+		}
+	}
+	return days, sessions.Err()
+}
+
+// summarizeStreaks works out the current and longest streaks of days on which
+// the goal was met, as of now in the learner's timezone. A streak survives up
+// to settings.StreakFreezes missed days; frozen days bridge the gap but do not
+// add to its length. Today only counts once its goal is met, and missing it
+// so far does not break the streak.
+func summarizeStreaks(days map[time.Time]*studyDay, settings *models.Settings, now time.Time) streakSummary {
+	loc := settingsLocation(settings)
+	today := civilDate(now, loc)
+
+	var summary streakSummary
+	summary.today = models.DailyGoalProgress{
+		Date:        today.Format("2006-01-02"),
+		GoalReviews: settings.DailyGoalReviews,
+		GoalMinutes: settings.DailyGoalMinutes,
+	}
+	if day := days[today]; day != nil {
+		summary.today.Reviews = day.reviews
+		summary.today.Minutes = day.seconds / 60
+	}
+	summary.today.Met = goalMet(days[today], settings)
+
+	var met []time.Time
+	for date, day := range days {
+		if !date.After(today) && goalMet(day, settings) {
+			met = append(met, date)
+		}
+	}
+	if len(met) == 0 {
+		return summary
+	}
+	sort.Slice(met, func(i, j int) bool { return met[i].Before(met[j]) })
+	metSet := make(map[time.Time]bool, len(met))
+	for _, date := range met {
+		metSet[date] = true
+	}
+
+	// Walk back from today (or yesterday, while today is still open),
+	// spending freezes on missed days. Freezes spent past the start of the
+	// streak bridged nothing, so only those before the last counted day stay.
+	date := today
+	if !summary.today.Met {
+		date = date.AddDate(0, 0, -1)
+	}
+	used := 0
+	for !date.Before(met[0]) {
+		if metSet[date] {
+			summary.current++
+			summary.freezesUsed = used
+		} else if used < settings.StreakFreezes {
+			used++
+		} else {
+			break
+		}
+		date = date.AddDate(0, 0, -1)
+	}
+
+	// The longest streak is the longest run of met days with no more missed
+	// days between its ends than there are freezes
+	for i, j := 0, 0; j < len(met); j++ {
+		for daysBetween(met[i], met[j])-(j-i) > settings.StreakFreezes {
+			i++
+		}
+		summary.longest = max(summary.longest, j-i+1)
+	}
+	summary.longest = max(summary.longest, summary.current)
+	return summary
+}
+
+// daysBetween counts the calendar days from a to b
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+)
+
+// StudyActivityInput holds the fields of a study activity to create or
+// change. Nil fields are left as they are on update; on create, Name is
+// required and an activity is enabled unless Enabled says otherwise.
+type StudyActivityInput struct {
+	Name         *string   `json:"name"`
+	ThumbnailURL *string   `json:"thumbnail_url"`
+	Description  *string   `json:"description"`
+	LaunchURL    *string   `json:"launch_url"`
+	PromptTypes  *[]string `json:"prompt_types"`
+	Enabled      *bool     `json:"enabled"`
+}
+
+type StudyActivityService struct {
+	db *sql.DB
+}
+
+func NewStudyActivityService() *StudyActivityService {
+	return &StudyActivityService{db: database.DB}
+}
+
+const studyActivitySelect = `
+	SELECT id, name, COALESCE(thumbnail_url, ''), COALESCE(description, ''),
+		   COALESCE(launch_url, ''), prompt_types, enabled
+	FROM study_activities
+`
+
+func scanStudyActivity(row interface{ Scan(...interface{}) error }) (*models.StudyActivity, error) {
+	var activity models.StudyActivity
+	var promptTypes string
+	if err := row.Scan(
+		&activity.ID, &activity.Name, &activity.ThumbnailURL, &activity.Description,
+		&activity.LaunchURL, &promptTypes, &activity.Enabled,
+	); err != nil {
+		return nil, err
+	}
+	activity.PromptTypes = make([]string, 0)
+	if err := json.Unmarshal([]byte(promptTypes), &activity.PromptTypes); err != nil {
+		return nil, err
+	}
+	return &activity, nil
+}
+
+// renderLaunchURL fills in a launch URL template for a session
+func renderLaunchURL(template string, session *models.StudySession) string {
+	return strings.NewReplacer(
+		"{study_session_id}", strconv.Itoa(session.ID),
+		"{group_id}", strconv.Itoa(session.GroupID),
+		"{study_activity_id}", strconv.Itoa(session.StudyActivityID),
+	).Replace(template)
+}
+
+// validateLaunchURL checks that a launch URL template only uses known
+// placeholders and is an absolute http(s) URL or a path on the portal
+func validateLaunchURL(template string) error {
+	rendered := template
+	for _, placeholder := range models.LaunchPlaceholders {
+		rendered = strings.ReplaceAll(rendered, placeholder, "1")
+	}
+	if start := strings.Index(rendered, "{"); start >= 0 {
+		unknown := rendered[start:]
+		if end := strings.Index(unknown, "}"); end >= 0 {
+			unknown = unknown[:end+1]
+		}
+		return &ValidationError{Field: "launch_url", Message: "uses unknown placeholder " + unknown + "; use " + strings.Join(models.LaunchPlaceholders, ", ")}
+	}
+	parsed, err := url.Parse(rendered)
+	if err != nil {
+		return &ValidationError{Field: "launch_url", Message: "is not a valid URL"}
+	}
+	if parsed.Scheme == "" && parsed.Host == "" && strings.HasPrefix(rendered, "/") {
+		return nil
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return &ValidationError{Field: "launch_url", Message: "must be an http(s) URL or a path starting with /"}
+	}
+	return nil
+}
+
+// validateStudyActivity trims and checks an activity before it is saved
+func (s *StudyActivityService) validateStudyActivity(id int, activity *models.StudyActivity) error {
+	activity.Name = strings.TrimSpace(activity.Name)
+	activity.LaunchURL = strings.TrimSpace(activity.LaunchURL)
+	if activity.Name == "" {
+		return &ValidationError{Field: "name", Message: "must not be empty"}
+	}
+	if activity.LaunchURL != "" {
+		if err := validateLaunchURL(activity.LaunchURL); err != nil {
+			return err
+		}
+	}
+	seen := map[string]bool{}
+	for _, promptType := range activity.PromptTypes {
+		switch promptType {
+		case models.DirectionJapaneseToEnglish, models.DirectionEnglishToJapanese, models.DirectionAudioToJapanese:
+		default:
+			return &ValidationError{Field: "prompt_types", Message: "must only contain jp_en, en_jp and audio_jp"}
+		}
+		if seen[promptType] {
+			return &ValidationError{Field: "prompt_types", Message: "must not repeat " + promptType}
+		}
+		seen[promptType] = true
+	}
+
+	var existing int
+	err := s.db.QueryRow(
+		"SELECT id FROM study_activities WHERE name = ? AND id != ?", activity.Name, id,
+	).Scan(&existing)
+	if err == nil {
+		return ErrDuplicateStudyActivity
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	return nil
+}
+
+// applyStudyActivityInput copies the given fields of input onto activity
+func applyStudyActivityInput(activity *models.StudyActivity, input StudyActivityInput) {
+	if input.Name != nil {
+		activity.Name = *input.Name
+	}
+	if input.ThumbnailURL != nil {
+		activity.ThumbnailURL = *input.ThumbnailURL
+	}
+	if input.Description != nil {
+		activity.Description = *input.Description
+	}
+	if input.LaunchURL != nil {
+		activity.LaunchURL = *input.LaunchURL
+	}
+	if input.PromptTypes != nil {
+		activity.PromptTypes = append(make([]string, 0), *input.PromptTypes...)
+	}
+	if input.Enabled != nil {
+		activity.Enabled = *input.Enabled
+	}
+}
+
+// GetStudyActivities lists study activities by name; a non-nil enabled lists
+// only the enabled or disabled ones
+func (s *StudyActivityService) GetStudyActivities(page, perPage int, enabled *bool) (*models.PaginatedResponse, error) {
+	where := ""
+	args := []interface{}{}
+	if enabled != nil {
+		where = "WHERE enabled = ?"
+		args = append(args, *enabled)
+	}
+
+	var total int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM study_activities "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := (page - 1) * perPage
+	rows, err := s.db.Query(studyActivitySelect+where+`
+		ORDER BY name, id
+		LIMIT ? OFFSET ?
+	`, append(args, perPage, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activities := make([]models.StudyActivity, 0)
+	for rows.Next() {
+		activity, err := scanStudyActivity(rows)
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, *activity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return newPaginatedResponse(activities, page, perPage, total), nil
+}
+
+func (s *StudyActivityService) GetStudyActivity(id int) (*models.StudyActivity, error) {
+	activity, err := scanStudyActivity(s.db.QueryRow(studyActivitySelect+"WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrStudyActivityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return activity, nil
+}
+
+// GetStudyActivitySessions lists the sessions a learner launched from an
+// activity, newest first
+func (s *StudyActivityService) GetStudyActivitySessions(userID, id, page, perPage int) (*models.PaginatedResponse, error) {
+	if found, err := exists(s.db, "study_activities", id); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, ErrStudyActivityNotFound
+	}
+	return listStudySessions(s.db, "WHERE ss.user_id = ? AND ss.study_activity_id = ?", []interface{}{userID, id}, page, perPage)
+}
+
+func (s *StudyActivityService) CreateStudyActivity(input StudyActivityInput) (*models.StudyActivity, error) {
+	activity := models.StudyActivity{PromptTypes: make([]string, 0), Enabled: true}
+	applyStudyActivityInput(&activity, input)
+	if err := s.validateStudyActivity(0, &activity); err != nil {
+		return nil, err
+	}
+
+	promptTypes, err := json.Marshal(activity.PromptTypes)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.db.Exec(`
+		INSERT INTO study_activities (name, thumbnail_url, description, launch_url, prompt_types, enabled, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, activity.Name, activity.ThumbnailURL, activity.Description, nullString(activity.LaunchURL),
+		string(promptTypes), activity.Enabled, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	activity.ID = int(id)
+	return &activity, nil
+}
+
+// UpdateStudyActivity changes the given fields of an activity
+func (s *StudyActivityService) UpdateStudyActivity(id int, input StudyActivityInput) (*models.StudyActivity, error) {
+	activity, err := s.GetStudyActivity(id)
+	if err != nil {
+		return nil, err
+	}
+	applyStudyActivityInput(activity, input)
+	if err := s.validateStudyActivity(id, activity); err != nil {
+		return nil, err
+	}
+
+	promptTypes, err := json.Marshal(activity.PromptTypes)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.db.Exec(`
+		UPDATE study_activities
+		SET name = ?, thumbnail_url = ?, description = ?, launch_url = ?, prompt_types = ?, enabled = ?, updated_at = ?
+		WHERE id = ?
+	`, activity.Name, activity.ThumbnailURL, activity.Description, nullString(activity.LaunchURL),
+		string(promptTypes), activity.Enabled, time.Now(), id)
+	if err != nil {
+		return nil, err
+	}
+	return activity, nil
+}
+
+// DeleteStudyActivity removes an activity that has never been launched;
+// activities with sessions should be disabled instead to keep their history
+func (s *StudyActivityService) DeleteStudyActivity(id int) error {
+	if found, err := exists(s.db, "study_activities", id); err != nil {
+		return err
+	} else if !found {
+		return ErrStudyActivityNotFound
+	}
+
+	var sessions int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM study_sessions WHERE study_activity_id = ?", id).Scan(&sessions); err != nil {
+		return err
+	}
+	if sessions > 0 {
+		return ErrStudyActivityHasSessions
+	}
+
+	_, err := s.db.Exec("DELETE FROM study_activities WHERE id = ?", id)
+	return err
+}
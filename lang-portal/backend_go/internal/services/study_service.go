@@ -5,6 +5,7 @@ import (
 	"time"
 	"github.com/mohawa/lang-portal/backend_go/internal/database"
 	"github.com/mohawa/lang-portal/backend_go/internal/models"
+	"github.com/mohawa/lang-portal/backend_go/internal/srs"
 )
 
 type StudyService struct {
@@ -15,73 +16,185 @@ func NewStudyService() *StudyService {
 	return &StudyService{db: database.DB}
 }
 
-func (s *StudyService) GetStudySessions(page, perPage int) (*models.PaginatedResponse, error) {
+// studySessionSelect is the shared projection for study session listings.
+// An active session runs until its last activity so far.
+const studySessionSelect = `
+	SELECT
+		ss.id,
+		COALESCE(sa.name, '') as activity_name,
+		g.name as group_name,
+		ss.status,
+		ss.created_at as start_time,
+		COALESCE(ss.ended_at, ss.last_activity_at, ss.created_at) as end_time,
+		COUNT(wri.word_id) as review_items_count
+	FROM study_sessions ss
+	JOIN groups g ON ss.group_id = g.id
+	LEFT JOIN study_activities sa ON ss.study_activity_id = sa.id
+	LEFT JOIN word_review_items wri ON ss.id = wri.study_session_id
+`
+
+func scanStudySession(row interface{ Scan(...interface{}) error }) (*models.StudySessionResponse, error) {
+	var session models.StudySessionResponse
+	var endTime string
+	if err := row.Scan(
+		&session.ID,
+		&session.ActivityName,
+		&session.GroupName,
+		&session.Status,
+		&session.StartTime,
+		&endTime,
+		&session.ReviewItemsCount,
+	); err != nil {
+		return nil, err
+	}
+	session.EndTime = parseTime(endTime)
+	session.DurationSeconds = int(session.EndTime.Sub(session.StartTime).Seconds())
+	return &session, nil
+}
+
+// listStudySessions returns a page of study sessions matching the given
+// WHERE clause (which may be empty), newest first
+func listStudySessions(db *sql.DB, where string, args []interface{}, page, perPage int) (*models.PaginatedResponse, error) {
 	var total int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM study_sessions").Scan(&total)
+	err := db.QueryRow("SELECT COUNT(*) FROM study_sessions ss "+where, args...).Scan(&total)
 	if err != nil {
 		return nil, err
 	}
 
 	offset := (page - 1) * perPage
-	rows, err := s.db.Query(`
-		SELECT 
-			ss.id,
-			sa.name as activity_name,
-			g.name as group_name,
-			ss.created_at as start_time,
-			ss.created_at as end_time,
-			COUNT(wri.word_id) as review_items_count
-		FROM study_sessions ss
-		JOIN groups g ON ss.group_id = g.id
-		JOIN study_activities sa ON ss.study_activity_id = sa.id
-		LEFT JOIN word_review_items wri ON ss.id = wri.study_session_id
+	rows, err := db.Query(studySessionSelect+where+`
 		GROUP BY ss.id
 		ORDER BY ss.created_at DESC
 		LIMIT ? OFFSET ?
-	`, perPage, offset)
+	`, append(args, perPage, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]models.StudySessionResponse, 0)
+	for rows.Next() {
+		session, err := scanStudySession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return newPaginatedResponse(sessions, page, perPage, total), nil
+}
+
+func (s *StudyService) GetStudySessions(userID, page, perPage int) (*models.PaginatedResponse, error) {
+	return listStudySessions(s.db, "WHERE ss.user_id = ?", []interface{}{userID}, page, perPage)
+}
+
+// GetStudySession returns one of the learner's study sessions. Other
+// learners' sessions are reported as not found.
+func (s *StudyService) GetStudySession(userID, id int) (*models.StudySessionResponse, error) {
+	session, err := scanStudySession(s.db.QueryRow(studySessionSelect+`
+		WHERE ss.id = ? AND ss.user_id = ?
+		GROUP BY ss.id
+	`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrStudySessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetStudySessionWords lists the words reviewed in a session in the order
+// they were first reviewed, with their correct and wrong counts for the session
+func (s *StudyService) GetStudySessionWords(userID, id, page, perPage int) (*models.PaginatedResponse, error) {
+	if err := s.checkOwner(userID, id); err != nil {
+		return nil, err
+	}
+
+	var total int
+	err := s.db.QueryRow(`
+		SELECT COUNT(DISTINCT wri.word_id)
+		FROM word_review_items wri
+		JOIN words w ON w.id = wri.word_id
+		WHERE wri.study_session_id = ?
+	`, id).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := (page - 1) * perPage
+	rows, err := s.db.Query(`
+		SELECT w.id, w.japanese, w.romaji, w.english,
+			   COUNT(CASE WHEN wri.correct = 1 THEN 1 END) as correct_count,
+			   COUNT(CASE WHEN wri.correct = 0 THEN 1 END) as wrong_count,
+			   MIN(wri.created_at) as first_reviewed_at,
+			   MAX(wri.created_at) as last_reviewed_at
+		FROM word_review_items wri
+		JOIN words w ON w.id = wri.word_id
+		WHERE wri.study_session_id = ?
+		GROUP BY w.id
+		ORDER BY MIN(wri.rowid)
+		LIMIT ? OFFSET ?
+	`, id, perPage, offset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var sessions []models.StudySession
+	words := make([]models.SessionWord, 0)
 	for rows.Next() {
-		var s models.StudySession
+		var w models.SessionWord
+		var firstReviewedAt, lastReviewedAt string
 		if err := rows.Scan(
-			&s.ID,
-			&s.ActivityName,
-			&s.GroupName,
-			&s.CreatedAt,
-			&s.CreatedAt,
-			&s.ReviewItemCount,
+			&w.ID, &w.Japanese, &w.Romaji, &w.English,
+			&w.CorrectCount, &w.WrongCount,
+			&firstReviewedAt, &lastReviewedAt,
 		); err != nil {
 			return nil, err
 		}
-		sessions = append(sessions, s)
+		w.ReviewOrder = offset + len(words) + 1
+		w.FirstReviewedAt = parseTime(firstReviewedAt)
+		w.LastReviewedAt = parseTime(lastReviewedAt)
+		words = append(words, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return &models.PaginatedResponse{
-		Items: sessions,
-		Pagination: models.Pagination{
-			CurrentPage:  page,
-			TotalPages:   (total + perPage - 1) / perPage,
-			TotalItems:   total,
-			ItemsPerPage: perPage,
-		},
-	}, nil
+	return newPaginatedResponse(words, page, perPage, total), nil
 }
 
-func (s *StudyService) CreateStudyActivity(groupID, studyActivityID int) (*models.StudySession, error) {
+// CreateStudyActivity starts a learner's study session of a group in an
+// enabled study activity. The session carries a launch token for it and the
+// activity's launch URL, filled in for the session and carrying the token.
+func (s *StudyService) CreateStudyActivity(userID, groupID, studyActivityID int) (*models.StudySession, error) {
+	if found, err := exists(s.db, "groups", groupID); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, ErrGroupNotFound
+	}
+	activity, err := NewStudyActivityService().GetStudyActivity(studyActivityID)
+	if err != nil {
+		return nil, err
+	}
+	if !activity.Enabled {
+		return nil, ErrStudyActivityDisabled
+	}
+
 	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, err
 	}
 	defer tx.Rollback()
 
+	createdAt := time.Now()
 	result, err := tx.Exec(`
-		INSERT INTO study_sessions (group_id, study_activity_id, created_at)
-		VALUES (?, ?, ?)
-	`, groupID, studyActivityID, time.Now())
+		INSERT INTO study_sessions (user_id, group_id, study_activity_id, created_at, status, last_activity_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, userID, groupID, studyActivityID, createdAt, models.SessionActive, createdAt)
 	if err != nil {
 		return nil, err
 	}
@@ -95,30 +208,227 @@ func (s *StudyService) CreateStudyActivity(groupID, studyActivityID int) (*model
 		return nil, err
 	}
 
-	return &models.StudySession{
+	session := &models.StudySession{
 		ID:              int(sessionID),
 		GroupID:         groupID,
+		CreatedAt:       createdAt,
 		StudyActivityID: studyActivityID,
-	}, nil
+		Status:          models.SessionActive,
+		ActivityName:    activity.Name,
+	}
+	token, expiresAt, err := issueLaunchToken(session.ID, userID, groupID, studyActivityID)
+	if err != nil {
+		return nil, err
+	}
+	session.LaunchToken = &models.LaunchToken{Token: token, ExpiresAt: expiresAt}
+	if activity.LaunchURL != "" {
+		session.LaunchURL = withLaunchToken(renderLaunchURL(activity.LaunchURL, session), token)
+	}
+	return session, nil
 }
 
-func (s *StudyService) GetStudyActivity(id int) (*models.StudyActivity, error) {
-	var activity models.StudyActivity
-	err := s.db.QueryRow(`
-		SELECT id, name, thumbnail_url, description
-		FROM study_activities
-		WHERE id = ?
-	`, id).Scan(&activity.ID, &activity.Name, &activity.ThumbnailURL, &activity.Description)
+// ReviewWord records a review of a word in a session and advances the word's
+// schedule. When the review carries a grade, the grade decides whether it
+// counts as correct; otherwise the plain correct flag is used.
+func (s *StudyService) ReviewWord(sessionID, wordID int, review models.WordReviewItem) (*models.WordReviewItem, error) {
+	if err := s.checkActive(sessionID); err != nil {
+		return nil, err
+	}
+	if found, err := exists(s.db, "words", wordID); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, ErrWordNotFound
+	}
+
+	review.WordID = wordID
+	review.StudySessionID = sessionID
+	review.CreatedAt = time.Now()
+
+	tx, err := s.db.Begin()
 	if err != nil {
 		return nil, err
 	}
-	return &activity, nil
+	defer tx.Rollback()
+
+	if _, err := recordReview(tx, &review); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// recordReview writes a review inside the caller's transaction, advances the
+// word's schedule for the session's learner and marks the session active at
+// the review's time. It returns the rowid of the new review item.
+func recordReview(tx *sql.Tx, review *models.WordReviewItem) (int64, error) {
+	quality := srs.QualityFromCorrect(review.Correct)
+	if review.Grade != "" {
+		grade := srs.Grade(review.Grade)
+		review.Correct = grade.Correct()
+		quality = grade.Quality()
+	}
+
+	var userID int
+	err := tx.QueryRow("SELECT user_id FROM study_sessions WHERE id = ?", review.StudySessionID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrStudySessionNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO word_review_items
+			(user_id, word_id, study_session_id, correct, grade, response_ms, answer, direction, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, userID, review.WordID, review.StudySessionID, review.Correct, nullString(review.Grade),
+		review.ResponseMs, nullString(review.Answer), nullString(review.Direction), review.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	rowID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := updateSchedule(tx, userID, review.WordID, quality, review.CreatedAt); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE study_sessions SET last_activity_at = ? WHERE id = ?",
+		review.CreatedAt, review.StudySessionID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return rowID, nil
 }
 
-func (s *StudyService) ReviewWord(sessionID, wordID int, correct bool) error {
-	_, err := s.db.Exec(`
-		INSERT INTO word_review_items (word_id, study_session_id, correct, created_at)
-		VALUES (?, ?, ?, ?)
-	`, wordID, sessionID, correct, time.Now())
+// AnswerWord checks a typed answer for a word and records the outcome as a
+// graded review, so every activity grades answers the same way
+func (s *StudyService) AnswerWord(sessionID, wordID int, answer, direction string, responseMs *int) (*models.AnswerResult, error) {
+	if err := s.checkActive(sessionID); err != nil {
+		return nil, err
+	}
+
+	var word models.Word
+	err := s.db.QueryRow(
+		"SELECT id, japanese, romaji, english FROM words WHERE id = ?", wordID,
+	).Scan(&word.ID, &word.Japanese, &word.Romaji, &word.English)
+	if err == sql.ErrNoRows {
+		return nil, ErrWordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := gradeAnswer(word, answer, direction)
+	result.Review, err = s.ReviewWord(sessionID, wordID, models.WordReviewItem{
+		Grade:      string(answerGrade(result.Verdict)),
+		ResponseMs: responseMs,
+		Answer:     answer,
+		Direction:  direction,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkActive returns ErrStudySessionNotFound or ErrStudySessionEnded unless
+// the session exists and is still active
+func (s *StudyService) checkActive(id int) error {
+	return checkSessionActive(s.db, id)
+}
+
+// checkOwner returns ErrStudySessionNotFound unless the session exists and
+// belongs to the learner
+func (s *StudyService) checkOwner(userID, id int) error {
+	var found int
+	err := s.db.QueryRow("SELECT 1 FROM study_sessions WHERE id = ? AND user_id = ?", id, userID).Scan(&found)
+	if err == sql.ErrNoRows {
+		return ErrStudySessionNotFound
+	}
 	return err
-} 
\ No newline at end of file
+}
+
+// checkSessionActive is checkActive for use on a database or inside a transaction
+func checkSessionActive(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, id int) error {
+	var status string
+	err := q.QueryRow("SELECT status FROM study_sessions WHERE id = ?", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return ErrStudySessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != models.SessionActive {
+		return ErrStudySessionEnded
+	}
+	return nil
+}
+
+// Heartbeat records that the learner is still working on their active
+// session so the idle sweeper leaves it open
+func (s *StudyService) Heartbeat(userID, id int) (*models.StudySessionResponse, error) {
+	if err := s.checkOwner(userID, id); err != nil {
+		return nil, err
+	}
+	if err := s.checkActive(id); err != nil {
+		return nil, err
+	}
+	_, err := s.db.Exec(
+		"UPDATE study_sessions SET last_activity_at = ? WHERE id = ?",
+		time.Now(), id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetStudySession(userID, id)
+}
+
+// EndStudySession marks the learner's active session as completed
+func (s *StudyService) EndStudySession(userID, id int) (*models.StudySessionResponse, error) {
+	if err := s.checkOwner(userID, id); err != nil {
+		return nil, err
+	}
+	if err := s.checkActive(id); err != nil {
+		return nil, err
+	}
+	if err := endSession(s.db, id, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.GetStudySession(userID, id)
+}
+
+// endSession marks a session completed at the given time if it is still active
+func endSession(q interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}, id int, at time.Time) error {
+	_, err := q.Exec(`
+		UPDATE study_sessions SET status = ?, ended_at = ?, last_activity_at = ?
+		WHERE id = ? AND status = ?
+	`, models.SessionCompleted, at, at, id, models.SessionActive)
+	return err
+}
+
+// CloseIdleSessions marks active sessions without activity for longer than
+// timeout as abandoned. They end at their last activity, so the idle time is
+// not counted towards their duration.
+func (s *StudyService) CloseIdleSessions(timeout time.Duration) (int, error) {
+	result, err := s.db.Exec(`
+		UPDATE study_sessions SET status = ?, ended_at = last_activity_at
+		WHERE status = ? AND julianday(last_activity_at) < julianday(?)
+	`, models.SessionAbandoned, models.SessionActive, time.Now().Add(-timeout))
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -2,8 +2,14 @@ package services
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
 	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/kana"
 	"github.com/mohawa/lang-portal/backend_go/internal/models"
+	"github.com/mohawa/lang-portal/backend_go/internal/srs"
 )
 
 type WordService struct {
@@ -14,65 +20,200 @@ func NewWordService() *WordService {
 	return &WordService{db: database.DB}
 }
 
-func (s *WordService) GetWords(page, perPage int) (*models.PaginatedResponse, error) {
+// lapsesColumn, masteryColumn and leechColumn compute a word's lapses,
+// srs.Mastery level and leech flag in SQL so that words can be filtered on them. They expect words
+// left joined to word_schedules as ws and to their review items as wri,
+// grouped by word. Words reviewed before scheduling existed have no schedule
+// and count their wrong answers as lapses.
+var (
+	lapsesColumn  = "COALESCE(ws.lapses, COUNT(CASE WHEN wri.correct = 0 THEN 1 END))"
+	masteryColumn = fmt.Sprintf(`CASE
+			WHEN ws.word_id IS NULL AND COUNT(wri.word_id) = 0 THEN '%s'
+			WHEN ws.word_id IS NULL OR ws.repetitions < %d THEN '%s'
+			WHEN ws.interval_days < %d THEN '%s'
+			WHEN ws.interval_days < %d THEN '%s'
+			ELSE '%s'
+		END`,
+		srs.MasteryNew,
+		srs.LearnedRepetitions, srs.MasteryLearning,
+		srs.MatureIntervalDays, srs.MasteryYoung,
+		srs.MasteredIntervalDays, srs.MasteryMature,
+		srs.MasteryMastered,
+	)
+	leechColumn = fmt.Sprintf("%s >= %d", lapsesColumn, srs.LeechLapses)
+)
+
+// learnerJoins left joins words to one learner's schedule as ws and review
+// items as wri, as the columns above expect. It takes the learner's id twice,
+// ahead of the arguments of any WHERE clause.
+const learnerJoins = `
+		LEFT JOIN word_schedules ws ON ws.word_id = w.id AND ws.user_id = ?
+		LEFT JOIN word_review_items wri ON w.id = wri.word_id AND wri.user_id = ?
+`
+
+// GetWords lists words with a learner's review counts, mastery and leech
+// flag. A non-empty mastery lists only the words at that level.
+func (s *WordService) GetWords(userID, page, perPage int, sortBy, order string, mastery srs.Mastery) (*models.PaginatedResponse, error) {
+	having := ""
+	args := []interface{}{userID, userID}
+	if mastery != "" {
+		having = "HAVING mastery = ?"
+		args = append(args, mastery)
+	}
+	query := `
+		SELECT w.id, w.japanese, w.romaji, w.english,
+			   COUNT(CASE WHEN wri.correct = 1 THEN 1 END) as correct_count,
+			   COUNT(CASE WHEN wri.correct = 0 THEN 1 END) as wrong_count,
+			   ` + masteryColumn + ` as mastery,
+			   ` + leechColumn + ` as leech
+		FROM words w` + learnerJoins + `
+		GROUP BY w.id
+		` + having
+
 	var total int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM words").Scan(&total)
+	err := s.db.QueryRow("SELECT COUNT(*) FROM ("+query+")", args...).Scan(&total)
 	if err != nil {
 		return nil, err
 	}
 
 	offset := (page - 1) * perPage
-	rows, err := s.db.Query(`
-		SELECT w.japanese, w.romaji, w.english,
+	rows, err := s.db.Query(query+`
+		`+orderClause(wordSortColumns, sortBy, order, "w.id")+`
+		LIMIT ? OFFSET ?
+	`, append(args, perPage, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := make([]models.WordWithStats, 0)
+	for rows.Next() {
+		var w models.WordWithStats
+		if err := rows.Scan(&w.ID, &w.Japanese, &w.Romaji, &w.English, &w.CorrectCount, &w.WrongCount, &w.Mastery, &w.Leech); err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return newPaginatedResponse(words, page, perPage, total), nil
+}
+
+// GetLeeches lists the words a learner has failed repeatedly, most failed
+// first, optionally only those in a group
+func (s *WordService) GetLeeches(userID, groupID, page, perPage int) (*models.PaginatedResponse, error) {
+	where := ""
+	args := []interface{}{userID, userID}
+	if groupID != 0 {
+		if found, err := exists(s.db, "groups", groupID); err != nil {
+			return nil, err
+		} else if !found {
+			return nil, ErrGroupNotFound
+		}
+		where = "WHERE w.id IN (SELECT word_id FROM words_groups WHERE group_id = ?)"
+		args = append(args, groupID)
+	}
+	query := `
+		SELECT w.id, w.japanese, w.romaji, w.english,
 			   COUNT(CASE WHEN wri.correct = 1 THEN 1 END) as correct_count,
-			   COUNT(CASE WHEN wri.correct = 0 THEN 1 END) as wrong_count
-		FROM words w
-		LEFT JOIN word_review_items wri ON w.id = wri.word_id
+			   COUNT(CASE WHEN wri.correct = 0 THEN 1 END) as wrong_count,
+			   ` + masteryColumn + ` as mastery,
+			   ` + lapsesColumn + ` as lapses,
+			   MAX(wri.created_at) as last_reviewed_at
+		FROM words w` + learnerJoins + `
+		` + where + `
 		GROUP BY w.id
+		HAVING ` + leechColumn
+
+	var total int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM ("+query+")", args...).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := (page - 1) * perPage
+	rows, err := s.db.Query(query+`
+		ORDER BY lapses DESC, wrong_count DESC, w.id
 		LIMIT ? OFFSET ?
-	`, perPage, offset)
+	`, append(args, perPage, offset)...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var words []models.WordWithStats
+	words := make([]models.LeechWord, 0)
 	for rows.Next() {
-		var w models.WordWithStats
-		if err := rows.Scan(&w.Japanese, &w.Romaji, &w.English, &w.CorrectCount, &w.WrongCount); err != nil {
+		var w models.LeechWord
+		var lastReviewedAt sql.NullString
+		if err := rows.Scan(
+			&w.ID, &w.Japanese, &w.Romaji, &w.English,
+			&w.CorrectCount, &w.WrongCount, &w.Mastery, &w.Lapses, &lastReviewedAt,
+		); err != nil {
 			return nil, err
 		}
+		w.Leech = true
+		if lastReviewedAt.Valid {
+			t := parseTime(lastReviewedAt.String)
+			w.LastReviewedAt = &t
+		}
 		words = append(words, w)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return &models.PaginatedResponse{
-		Items: words,
-		Pagination: models.Pagination{
-			CurrentPage:  page,
-			TotalPages:   (total + perPage - 1) / perPage,
-			TotalItems:   total,
-			ItemsPerPage: perPage,
-		},
-	}, nil
+	return newPaginatedResponse(words, page, perPage, total), nil
 }
 
-func (s *WordService) GetWord(id int) (*models.WordResponse, error) {
+// GetWord returns a word with its groups and a learner's stats for it
+func (s *WordService) GetWord(userID, id int) (*models.WordResponse, error) {
 	var word models.WordResponse
+	var averageResponseMs sql.NullFloat64
+	var parts sql.NullString
 	err := s.db.QueryRow(`
-		SELECT w.japanese, w.romaji, w.english,
+		SELECT w.id, w.japanese, w.romaji, w.english, w.parts,
 			   COUNT(CASE WHEN wri.correct = 1 THEN 1 END) as correct_count,
-			   COUNT(CASE WHEN wri.correct = 0 THEN 1 END) as wrong_count
-		FROM words w
-		LEFT JOIN word_review_items wri ON w.id = wri.word_id
+			   COUNT(CASE WHEN wri.correct = 0 THEN 1 END) as wrong_count,
+			   COUNT(CASE WHEN wri.grade = 'again' THEN 1 END) as again_count,
+			   COUNT(CASE WHEN wri.grade = 'hard' THEN 1 END) as hard_count,
+			   COUNT(CASE WHEN wri.grade = 'good' THEN 1 END) as good_count,
+			   COUNT(CASE WHEN wri.grade = 'easy' THEN 1 END) as easy_count,
+			   AVG(wri.response_ms) as average_response_ms,
+			   `+masteryColumn+` as mastery,
+			   `+lapsesColumn+` as lapses,
+			   `+leechColumn+` as leech
+		FROM words w`+learnerJoins+`
 		WHERE w.id = ?
 		GROUP BY w.id
-	`, id).Scan(&word.Japanese, &word.Romaji, &word.English, &word.Stats.CorrectCount, &word.Stats.WrongCount)
+	`, userID, userID, id).Scan(
+		&word.ID, &word.Japanese, &word.Romaji, &word.English, &parts,
+		&word.Stats.CorrectCount, &word.Stats.WrongCount,
+		&word.Stats.Grades.Again, &word.Stats.Grades.Hard, &word.Stats.Grades.Good, &word.Stats.Grades.Easy,
+		&averageResponseMs,
+		&word.Stats.Mastery, &word.Stats.Lapses, &word.Stats.Leech,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrWordNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
+	word.Parts = make([]models.WordPart, 0)
+	if parts.Valid {
+		if err := json.Unmarshal([]byte(parts.String), &word.Parts); err != nil {
+			return nil, err
+		}
+	}
+	if averageResponseMs.Valid {
+		word.Stats.AverageResponseMs = &averageResponseMs.Float64
+	}
+	word.Groups = make([]models.Group, 0)
 
 	rows, err := s.db.Query(`
-		SELECT g.id, g.name
+		SELECT g.id, g.name,
+			   (SELECT COUNT(*) FROM words_groups WHERE group_id = g.id) as word_count
 		FROM groups g
 		JOIN words_groups wg ON g.id = wg.group_id
 		WHERE wg.word_id = ?
@@ -84,11 +225,268 @@ func (s *WordService) GetWord(id int) (*models.WordResponse, error) {
 
 	for rows.Next() {
 		var group models.Group
-		if err := rows.Scan(&group.ID, &group.Name); err != nil {
+		if err := rows.Scan(&group.ID, &group.Name, &group.WordCount); err != nil {
 			return nil, err
 		}
 		word.Groups = append(word.Groups, group)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return &word, nil
-} 
\ No newline at end of file
+}
+
+// CheckReading reports whether answer gives the word in Japanese, either
+// exactly as written or as its reading in kana or any style of romaji
+func (s *WordService) CheckReading(id int, answer string) (bool, error) {
+	var word models.Word
+	err := s.db.QueryRow("SELECT japanese, romaji FROM words WHERE id = ?", id).Scan(&word.Japanese, &word.Romaji)
+	if err == sql.ErrNoRows {
+		return false, ErrWordNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	return readingMatches(word, answer), nil
+}
+
+// readingMatches reports whether answer is exactly the word's reading. Long
+// and short vowels differ here; near misses are left to the typo check.
+func readingMatches(word models.Word, answer string) bool {
+	answer = strings.TrimSpace(kana.NormalizeWidth(answer))
+	if answer == "" {
+		return false
+	}
+	if answer == word.Japanese || kana.SameReading(answer, word.Romaji) {
+		return true
+	}
+	return kana.IsKana(word.Japanese) && kana.ReadingMatches(word.Japanese, answer)
+}
+
+// validateWord trims the word's fields and checks them for consistency
+func validateWord(word *models.Word) error {
+	word.Japanese = strings.TrimSpace(kana.NormalizeWidth(word.Japanese))
+	word.Romaji = strings.TrimSpace(kana.NormalizeWidth(word.Romaji))
+	word.English = strings.TrimSpace(kana.NormalizeWidth(word.English))
+
+	if word.Japanese == "" {
+		return &ValidationError{Field: "japanese", Message: "must not be empty"}
+	}
+	if word.Romaji == "" {
+		word.Romaji = deriveRomaji(*word)
+	}
+	if word.Romaji == "" {
+		return &ValidationError{Field: "romaji", Message: "must not be empty when japanese contains kanji and no parts are given"}
+	}
+	if word.English == "" {
+		return &ValidationError{Field: "english", Message: "must not be empty"}
+	}
+	for _, r := range word.Japanese {
+		if r < unicode.MaxASCII && unicode.IsLetter(r) {
+			return &ValidationError{Field: "japanese", Message: "must not contain latin letters"}
+		}
+	}
+	for _, r := range word.Romaji {
+		if unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Han) {
+			return &ValidationError{Field: "romaji", Message: "must be written in latin letters"}
+		}
+	}
+	if !kana.IsRomaji(word.Romaji) {
+		return &ValidationError{Field: "romaji", Message: "must be written in Hepburn, Kunrei-shiki or Nihon-shiki"}
+	}
+	if !kana.ReadingMatches(word.Japanese, word.Romaji) {
+		return &ValidationError{
+			Field:   "romaji",
+			Message: "does not match the reading of " + word.Japanese + " (expected " + kana.ToRomaji(word.Japanese) + ")",
+		}
+	}
+	if err := validateParts(word); err != nil {
+		return err
+	}
+
+	// Parts give the reading of words with kanji, which cannot be derived
+	if reading := deriveRomaji(*word); len(word.Parts) > 0 && !kana.SameReading(word.Romaji, reading) {
+		return &ValidationError{
+			Field:   "romaji",
+			Message: "does not match the reading of its parts (expected " + reading + ")",
+		}
+	}
+	return nil
+}
+
+// deriveRomaji fills in a missing reading from the kana of the word or,
+// for words with kanji, from the romaji of its parts
+func deriveRomaji(word models.Word) string {
+	if kana.IsKana(word.Japanese) {
+		return kana.ToRomaji(word.Japanese)
+	}
+	var romaji strings.Builder
+	for _, part := range word.Parts {
+		if len(part.Romaji) == 0 {
+			return ""
+		}
+		romaji.WriteString(strings.Join(part.Romaji, ""))
+	}
+	return romaji.String()
+}
+
+// validateParts trims the word's parts and checks that together they spell
+// out the word
+func validateParts(word *models.Word) error {
+	if len(word.Parts) == 0 {
+		return nil
+	}
+
+	var spelled strings.Builder
+	for i := range word.Parts {
+		part := &word.Parts[i]
+		part.Kanji = strings.TrimSpace(part.Kanji)
+		if part.Kanji == "" {
+			return &ValidationError{Field: "parts", Message: "must give the kanji of every part"}
+		}
+		spelled.WriteString(part.Kanji)
+
+		for _, readings := range [][]string{part.On, part.Kun, part.Romaji} {
+			for j := range readings {
+				readings[j] = strings.TrimSpace(readings[j])
+				if readings[j] == "" {
+					return &ValidationError{Field: "parts", Message: "must not contain empty readings"}
+				}
+			}
+		}
+		if len(part.Romaji) == 0 {
+			return &ValidationError{Field: "parts", Message: "must give the romaji of " + part.Kanji}
+		}
+	}
+
+	if spelled.String() != word.Japanese {
+		return &ValidationError{Field: "parts", Message: "must spell out " + word.Japanese}
+	}
+	return nil
+}
+
+// partsColumn encodes a word's parts for the parts column, storing NULL when
+// the word has none
+func partsColumn(parts []models.WordPart) (sql.NullString, error) {
+	if len(parts) == 0 {
+		return sql.NullString{}, nil
+	}
+	encoded, err := json.Marshal(parts)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+// checkDuplicate returns ErrDuplicateWord when another word has the same
+// japanese and a reading that only differs in romanisation style
+func (s *WordService) checkDuplicate(word models.Word) error {
+	rows, err := s.db.Query(
+		"SELECT romaji FROM words WHERE japanese = ? AND id != ?",
+		word.Japanese, word.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	key := kana.ReadingKey(word.Romaji)
+	for rows.Next() {
+		var romaji string
+		if err := rows.Scan(&romaji); err != nil {
+			return err
+		}
+		if kana.ReadingKey(romaji) == key {
+			return ErrDuplicateWord
+		}
+	}
+	return rows.Err()
+}
+
+func (s *WordService) CreateWord(word models.Word) (*models.Word, error) {
+	word.ID = 0
+	if err := validateWord(&word); err != nil {
+		return nil, err
+	}
+	if err := s.checkDuplicate(word); err != nil {
+		return nil, err
+	}
+
+	parts, err := partsColumn(word.Parts)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(
+		"INSERT INTO words (japanese, romaji, english, parts) VALUES (?, ?, ?, ?)",
+		word.Japanese, word.Romaji, word.English, parts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	word.ID = int(id)
+	return &word, nil
+}
+
+func (s *WordService) UpdateWord(id int, word models.Word) (*models.Word, error) {
+	if found, err := exists(s.db, "words", id); err != nil {
+		return nil, err
+	} else if !found {
+		return nil, ErrWordNotFound
+	}
+
+	word.ID = id
+	if err := validateWord(&word); err != nil {
+		return nil, err
+	}
+	if err := s.checkDuplicate(word); err != nil {
+		return nil, err
+	}
+
+	parts, err := partsColumn(word.Parts)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec(
+		"UPDATE words SET japanese = ?, romaji = ?, english = ?, parts = ? WHERE id = ?",
+		word.Japanese, word.Romaji, word.English, parts, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &word, nil
+}
+
+// DeleteWord removes a word together with its group links, review history
+// and schedule so no rows are left pointing at it
+func (s *WordService) DeleteWord(id int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"word_schedules", "word_review_items", "words_groups"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE word_id = ?", id); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec("DELETE FROM words WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrWordNotFound
+	}
+
+	return tx.Commit()
+}
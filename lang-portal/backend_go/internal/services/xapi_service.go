@@ -0,0 +1,566 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/launchtoken"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+)
+
+// maxStatementsPerPage caps the limit a statement query may ask for
+const maxStatementsPerPage = 500
+
+var (
+	uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+	// Activity IRIs that name portal records end in /words/{id},
+	// /study_sessions/{id} or /study_activities/{id}, whatever the host,
+	// e.g. http://localhost:8080/api/words/1
+	portalActivityPattern = regexp.MustCompile(`/(words|study_sessions|study_activities)/(\d+)/?$`)
+
+	// durationPattern reads the ISO 8601 durations xAPI results use, e.g. PT1.5S
+	durationPattern = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+)
+
+// StatementQuery filters a statement listing. Zero values do not filter.
+// Agent matches the statement's actor and Activity its object's id.
+type StatementQuery struct {
+	Agent        *models.XAPIAgent
+	Verb         string
+	Activity     string
+	Registration string
+	Since        time.Time
+	Until        time.Time
+	Limit        int
+	Ascending    bool
+	Page         int
+}
+
+type XAPIService struct {
+	db *sql.DB
+}
+
+func NewXAPIService() *XAPIService {
+	return &XAPIService{db: database.DB}
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// isIRI reports whether value is an absolute IRI
+func isIRI(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && parsed.Scheme != ""
+}
+
+// portalActivity reads the kind and id of a portal record from an activity IRI
+func portalActivity(iri string) (string, int, bool) {
+	match := portalActivityPattern.FindStringSubmatch(iri)
+	if match == nil {
+		return "", 0, false
+	}
+	id, err := strconv.Atoi(match[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return match[1], id, true
+}
+
+// parseDuration converts an ISO 8601 duration into a time.Duration
+func parseDuration(value string) (time.Duration, bool) {
+	match := durationPattern.FindStringSubmatch(value)
+	if match == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, false
+	}
+	var total float64
+	for i, unit := range []float64{24 * 3600, 3600, 60, 1} {
+		if match[i+1] != "" {
+			n, _ := strconv.ParseFloat(match[i+1], 64)
+			total += n * unit
+		}
+	}
+	return time.Duration(total * float64(time.Second)), true
+}
+
+// agentKey identifies an agent by its inverse functional identifier, or
+// returns "" for anonymous groups
+func agentKey(agent *models.XAPIAgent) string {
+	switch {
+	case agent.Mbox != "":
+		return "mbox:" + strings.ToLower(agent.Mbox)
+	case agent.MboxSHA1Sum != "":
+		return "mbox_sha1sum:" + strings.ToLower(agent.MboxSHA1Sum)
+	case agent.OpenID != "":
+		return "openid:" + agent.OpenID
+	case agent.Account != nil:
+		return "account:" + agent.Account.HomePage + "|" + agent.Account.Name
+	}
+	return ""
+}
+
+// validateAgent checks that an agent has exactly one identifier, or is an
+// anonymous group with members
+func validateAgent(agent *models.XAPIAgent, field string) error {
+	if agent == nil {
+		return &ValidationError{Field: field, Message: "is required"}
+	}
+	identifiers := 0
+	for _, set := range []bool{agent.Mbox != "", agent.MboxSHA1Sum != "", agent.OpenID != "", agent.Account != nil} {
+		if set {
+			identifiers++
+		}
+	}
+	if identifiers > 1 {
+		return &ValidationError{Field: field, Message: "must have only one of mbox, mbox_sha1sum, openid or account"}
+	}
+	if identifiers == 0 && !(agent.ObjectType == models.XAPIObjectGroup && len(agent.Member) > 0) {
+		return &ValidationError{Field: field, Message: "must be identified by mbox, mbox_sha1sum, openid or account"}
+	}
+	if agent.Mbox != "" && !strings.HasPrefix(strings.ToLower(agent.Mbox), "mailto:") {
+		return &ValidationError{Field: field + ".mbox", Message: "must be a mailto: IRI"}
+	}
+	if agent.Account != nil && (!isIRI(agent.Account.HomePage) || agent.Account.Name == "") {
+		return &ValidationError{Field: field + ".account", Message: "needs a homePage IRI and a name"}
+	}
+	return nil
+}
+
+// ParseAgent reads an agent given as JSON, as in the agent query parameter
+func ParseAgent(data string) (*models.XAPIAgent, error) {
+	var agent models.XAPIAgent
+	if err := json.Unmarshal([]byte(data), &agent); err != nil {
+		return nil, &ValidationError{Field: "agent", Message: "must be a JSON agent"}
+	}
+	if err := validateAgent(&agent, "agent"); err != nil {
+		return nil, err
+	}
+	if agentKey(&agent) == "" {
+		return nil, &ValidationError{Field: "agent", Message: "must be identified by mbox, mbox_sha1sum, openid or account"}
+	}
+	return &agent, nil
+}
+
+// validateStatement checks the parts of a statement the store relies on
+func validateStatement(statement *models.XAPIStatement) error {
+	if statement.ID != "" && !uuidPattern.MatchString(statement.ID) {
+		return &ValidationError{Field: "id", Message: "must be a UUID"}
+	}
+	if err := validateAgent(statement.Actor, "actor"); err != nil {
+		return err
+	}
+	if statement.Verb == nil || !isIRI(statement.Verb.ID) {
+		return &ValidationError{Field: "verb.id", Message: "must be an IRI"}
+	}
+	if statement.Object == nil {
+		return &ValidationError{Field: "object", Message: "is required"}
+	}
+	switch statement.Object.ObjectType {
+	case "", models.XAPIObjectActivity:
+		if !isIRI(statement.Object.ID) {
+			return &ValidationError{Field: "object.id", Message: "must be an IRI"}
+		}
+	case models.XAPIObjectStatementRef:
+		if !uuidPattern.MatchString(statement.Object.ID) {
+			return &ValidationError{Field: "object.id", Message: "must be a statement UUID"}
+		}
+	case models.XAPIObjectAgent, models.XAPIObjectGroup, models.XAPIObjectSubStatement:
+	default:
+		return &ValidationError{Field: "object.objectType", Message: "is not a known object type"}
+	}
+	if statement.Verb.ID == models.XAPIVerbVoided && statement.Object.ObjectType != models.XAPIObjectStatementRef {
+		return &ValidationError{Field: "object", Message: "of a voiding statement must be a StatementRef"}
+	}
+	if statement.Timestamp != "" {
+		if _, err := time.Parse(time.RFC3339Nano, statement.Timestamp); err != nil {
+			return &ValidationError{Field: "timestamp", Message: "must be an ISO 8601 timestamp"}
+		}
+	}
+	if statement.Result != nil && statement.Result.Duration != "" {
+		if _, ok := parseDuration(statement.Result.Duration); !ok {
+			return &ValidationError{Field: "result.duration", Message: "must be an ISO 8601 duration"}
+		}
+	}
+	if statement.Context != nil && statement.Context.Registration != "" && !uuidPattern.MatchString(statement.Context.Registration) {
+		return &ValidationError{Field: "context.registration", Message: "must be a UUID"}
+	}
+	return nil
+}
+
+// statementSession finds the study session a statement is about: its object
+// when that is a session, otherwise a session among its context activities
+func statementSession(statement *models.XAPIStatement) (int, bool) {
+	if kind, id, ok := portalActivity(statement.Object.ID); ok && kind == "study_sessions" {
+		return id, true
+	}
+	if statement.Context == nil || statement.Context.ContextActivities == nil {
+		return 0, false
+	}
+	activities := statement.Context.ContextActivities
+	for _, list := range []models.XAPIActivityList{activities.Parent, activities.Grouping, activities.Other, activities.Category} {
+		for _, activity := range list {
+			if kind, id, ok := portalActivity(activity.ID); ok && kind == "study_sessions" {
+				return id, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// sameStatement reports whether a stored statement matches a resubmitted
+// one, ignoring the properties the store fills in
+func sameStatement(stored string, submitted map[string]interface{}) bool {
+	var existing map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(stored))
+	decoder.UseNumber()
+	if err := decoder.Decode(&existing); err != nil {
+		return false
+	}
+	for _, property := range []string{"stored", "authority"} {
+		delete(existing, property)
+		delete(submitted, property)
+	}
+	// Ignore what the store filled in the first time round
+	for _, property := range []string{"version", "timestamp"} {
+		if _, ok := submitted[property]; !ok {
+			delete(existing, property)
+		}
+	}
+	return reflect.DeepEqual(existing, submitted)
+}
+
+// StoreStatements stores statements and applies their effects, all in one
+// transaction, returning their ids in order. Statements without an id are
+// given one. Resending a stored statement unchanged is a no-op; reusing its
+// id for a different statement returns ErrStatementConflict.
+//
+// "answered" statements about a word (an activity IRI ending in
+// /words/{id}) record a review in the study session named among the context
+// activities, correct when result.success is true. "completed" statements
+// about a study session end it. Voiding a statement hides it from queries
+// and removes the review it recorded; the word's schedule is not rewound.
+//
+// Statements that change a study session need a launch token for it.
+// Statements belong to the learner the launch token was issued to, or to
+// userID when there is none.
+func (s *XAPIService) StoreStatements(raw []json.RawMessage, userID int, launch *launchtoken.Claims) ([]string, error) {
+	if launch != nil {
+		userID = launch.UserID
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids := make([]string, 0, len(raw))
+	seen := map[string]bool{}
+	for i, data := range raw {
+		id, err := storeStatement(tx, data, userID, launch)
+		if err != nil {
+			if validationErr, ok := err.(*ValidationError); ok && len(raw) > 1 {
+				validationErr.Field = "statements[" + strconv.Itoa(i) + "]." + validationErr.Field
+			}
+			return nil, err
+		}
+		if seen[id] {
+			return nil, &ValidationError{Field: "statements[" + strconv.Itoa(i) + "].id", Message: "is repeated in the batch"}
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// storeStatement validates, stores and applies a single statement of a learner
+func storeStatement(tx *sql.Tx, data json.RawMessage, userID int, launch *launchtoken.Claims) (string, error) {
+	var document map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil || document == nil {
+		return "", &ValidationError{Field: "statement", Message: "must be a JSON object"}
+	}
+	var statement models.XAPIStatement
+	if err := json.Unmarshal(data, &statement); err != nil {
+		return "", &ValidationError{Field: "statement", Message: "is malformed: " + err.Error()}
+	}
+	if err := validateStatement(&statement); err != nil {
+		return "", err
+	}
+
+	if statement.ID == "" {
+		id, err := newUUID()
+		if err != nil {
+			return "", err
+		}
+		statement.ID = id
+	} else {
+		statement.ID = strings.ToLower(statement.ID)
+		var stored string
+		err := tx.QueryRow("SELECT statement FROM xapi_statements WHERE id = ?", statement.ID).Scan(&stored)
+		if err == nil {
+			document["id"] = statement.ID
+			if sameStatement(stored, document) {
+				return statement.ID, nil
+			}
+			return "", ErrStatementConflict
+		}
+		if err != sql.ErrNoRows {
+			return "", err
+		}
+	}
+
+	now := time.Now().UTC()
+	timestamp := now
+	if statement.Timestamp != "" {
+		timestamp, _ = time.Parse(time.RFC3339Nano, statement.Timestamp)
+	} else {
+		document["timestamp"] = now.Format(time.RFC3339Nano)
+	}
+	document["id"] = statement.ID
+	document["stored"] = now.Format(time.RFC3339Nano)
+	if _, ok := document["version"]; !ok {
+		document["version"] = models.XAPIVersion
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return "", err
+	}
+
+	registration := ""
+	if statement.Context != nil {
+		registration = strings.ToLower(statement.Context.Registration)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO xapi_statements (id, user_id, verb_id, object_id, actor_key, registration, statement, stored)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, statement.ID, userID, statement.Verb.ID, nullString(statement.Object.ID), nullString(agentKey(statement.Actor)),
+		nullString(registration), string(encoded), now)
+	if err != nil {
+		return "", err
+	}
+
+	switch statement.Verb.ID {
+	case models.XAPIVerbVoided:
+		err = voidStatement(tx, strings.ToLower(statement.Object.ID), userID, launch)
+	case models.XAPIVerbAnswered:
+		err = recordAnsweredStatement(tx, &statement, timestamp, launch)
+	case models.XAPIVerbCompleted:
+		err = recordCompletedStatement(tx, &statement, timestamp, launch)
+	}
+	if err != nil {
+		return "", err
+	}
+	return statement.ID, nil
+}
+
+// voidStatement marks one of a learner's statements voided and removes the
+// review it recorded
+func voidStatement(tx *sql.Tx, id string, userID int, launch *launchtoken.Claims) error {
+	var verb string
+	err := tx.QueryRow("SELECT verb_id FROM xapi_statements WHERE id = ? AND user_id = ?", id, userID).Scan(&verb)
+	if err == sql.ErrNoRows {
+		return &ValidationError{Field: "object.id", Message: "does not refer to a stored statement"}
+	}
+	if err != nil {
+		return err
+	}
+	if verb == models.XAPIVerbVoided {
+		return &ValidationError{Field: "object.id", Message: "refers to a voiding statement, which cannot be voided"}
+	}
+
+	// Removing a review takes a launch token for its session
+	var sessionID int
+	err = tx.QueryRow("SELECT study_session_id FROM word_review_items WHERE xapi_statement_id = ?", id).Scan(&sessionID)
+	if err == nil {
+		if err := authorizeSession(tx, launch, sessionID); err != nil {
+			return err
+		}
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE xapi_statements SET voided = 1 WHERE id = ?", id); err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM word_review_items WHERE xapi_statement_id = ?", id)
+	return err
+}
+
+// recordAnsweredStatement records an "answered" statement about a word as a
+// review in its study session. Statements about other activities are only stored.
+func recordAnsweredStatement(tx *sql.Tx, statement *models.XAPIStatement, at time.Time, launch *launchtoken.Claims) error {
+	kind, wordID, ok := portalActivity(statement.Object.ID)
+	if !ok || kind != "words" {
+		return nil
+	}
+	sessionID, ok := statementSession(statement)
+	if !ok {
+		return &ValidationError{Field: "context.contextActivities", Message: "must include the study session the word was answered in"}
+	}
+	if statement.Result == nil || statement.Result.Success == nil {
+		return &ValidationError{Field: "result.success", Message: "is required to record an answer"}
+	}
+
+	if err := authorizeSession(tx, launch, sessionID); err != nil {
+		return err
+	}
+	if err := checkSessionActive(tx, sessionID); err != nil {
+		return err
+	}
+	var found int
+	err := tx.QueryRow("SELECT 1 FROM words WHERE id = ?", wordID).Scan(&found)
+	if err == sql.ErrNoRows {
+		return ErrWordNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	review := models.WordReviewItem{
+		WordID:         wordID,
+		StudySessionID: sessionID,
+		Correct:        *statement.Result.Success,
+		CreatedAt:      at,
+	}
+	if statement.Result.Response != nil {
+		review.Answer = *statement.Result.Response
+	}
+	if duration, ok := parseDuration(statement.Result.Duration); ok {
+		responseMs := int(duration.Milliseconds())
+		review.ResponseMs = &responseMs
+	}
+
+	rowID, err := recordReview(tx, &review)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE word_review_items SET xapi_statement_id = ? WHERE rowid = ?", statement.ID, rowID)
+	return err
+}
+
+// recordCompletedStatement ends the study session a "completed" statement is
+// about. Sessions that have already ended are left as they are.
+func recordCompletedStatement(tx *sql.Tx, statement *models.XAPIStatement, at time.Time, launch *launchtoken.Claims) error {
+	sessionID, ok := statementSession(statement)
+	if !ok {
+		return nil
+	}
+	if err := authorizeSession(tx, launch, sessionID); err != nil {
+		return err
+	}
+	return endSession(tx, sessionID, at)
+}
+
+// GetStatement returns one of a learner's statements by id. Voided
+// statements are only returned when voided is set, and only voided
+// statements then.
+func (s *XAPIService) GetStatement(userID int, id string, voided bool) (json.RawMessage, error) {
+	var statement string
+	var isVoided bool
+	err := s.db.QueryRow(
+		"SELECT statement, voided FROM xapi_statements WHERE id = ? AND user_id = ?", strings.ToLower(id), userID,
+	).Scan(&statement, &isVoided)
+	if err == sql.ErrNoRows || (err == nil && isVoided != voided) {
+		return nil, ErrStatementNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(statement), nil
+}
+
+// GetStatements lists a learner's statements that have not been voided,
+// newest first unless query.Ascending is set, and reports whether there are
+// more pages
+func (s *XAPIService) GetStatements(userID int, query StatementQuery) ([]json.RawMessage, bool, error) {
+	if query.Limit <= 0 || query.Limit > maxStatementsPerPage {
+		query.Limit = maxStatementsPerPage
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+
+	where := "WHERE voided = 0 AND user_id = ?"
+	args := []interface{}{userID}
+	if query.Agent != nil {
+		where += " AND actor_key = ?"
+		args = append(args, agentKey(query.Agent))
+	}
+	if query.Verb != "" {
+		where += " AND verb_id = ?"
+		args = append(args, query.Verb)
+	}
+	if query.Activity != "" {
+		where += " AND object_id = ?"
+		args = append(args, query.Activity)
+	}
+	if query.Registration != "" {
+		where += " AND registration = ?"
+		args = append(args, strings.ToLower(query.Registration))
+	}
+	if !query.Since.IsZero() {
+		where += " AND julianday(stored) > julianday(?)"
+		args = append(args, query.Since.UTC())
+	}
+	if !query.Until.IsZero() {
+		where += " AND julianday(stored) <= julianday(?)"
+		args = append(args, query.Until.UTC())
+	}
+	// Statements are stored in order, so rowid order is stored order
+	order := "DESC"
+	if query.Ascending {
+		order = "ASC"
+	}
+
+	rows, err := s.db.Query(`
+		SELECT statement FROM xapi_statements
+		`+where+`
+		ORDER BY rowid `+order+`
+		LIMIT ? OFFSET ?
+	`, append(args, query.Limit+1, (query.Page-1)*query.Limit)...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	statements := make([]json.RawMessage, 0)
+	for rows.Next() {
+		var statement string
+		if err := rows.Scan(&statement); err != nil {
+			return nil, false, err
+		}
+		statements = append(statements, json.RawMessage(statement))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	more := len(statements) > query.Limit
+	if more {
+		statements = statements[:query.Limit]
+	}
+	return statements, more, nil
+}
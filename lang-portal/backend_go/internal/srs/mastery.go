@@ -0,0 +1,40 @@
+package srs
+
+// Mastery is how well a word is known, judged from its schedule. A word
+// leaves learning after LearnedRepetitions recalls in a row and then moves up
+// as its interval grows.
+type Mastery string
+
+const (
+	// MasteryNew words have never been reviewed
+	MasteryNew Mastery = "new"
+	// MasteryLearning words have not yet been recalled twice in a row
+	MasteryLearning Mastery = "learning"
+	// MasteryYoung words are recalled reliably over short intervals
+	MasteryYoung Mastery = "young"
+	// MasteryMature words have an interval of at least MatureIntervalDays
+	MasteryMature Mastery = "mature"
+	// MasteryMastered words have an interval of at least MasteredIntervalDays
+	MasteryMastered Mastery = "mastered"
+)
+
+const (
+	// LearnedRepetitions is how many recalls in a row take a word out of learning
+	LearnedRepetitions   = 2
+	MatureIntervalDays   = 21
+	MasteredIntervalDays = 90
+
+	// LeechLapses is how many times a word can be failed before it is
+	// flagged as a leech, a word that needs different treatment such as a
+	// mnemonic rather than more reviews
+	LeechLapses = 4
+)
+
+// Valid reports whether m is one of the known mastery levels
+func (m Mastery) Valid() bool {
+	switch m {
+	case MasteryNew, MasteryLearning, MasteryYoung, MasteryMature, MasteryMastered:
+		return true
+	}
+	return false
+}
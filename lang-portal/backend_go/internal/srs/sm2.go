@@ -0,0 +1,134 @@
+// Package srs implements the SM-2 spaced repetition algorithm used to decide
+// when each word should be reviewed again.
+package srs
+
+import (
+	"math"
+	"time"
+)
+
+// Quality is an SM-2 recall grade from 0 (blackout) to 5 (perfect recall)
+type Quality int
+
+const (
+	QualityBlackout Quality = 0
+	QualityWrong    Quality = 1
+	QualityHard     Quality = 3
+	QualityGood     Quality = 4
+	QualityEasy     Quality = 5
+)
+
+const (
+	DefaultEaseFactor = 2.5
+	MinEaseFactor     = 1.3
+)
+
+// State is the scheduling state of a single word
+type State struct {
+	EaseFactor   float64
+	IntervalDays int
+	Repetitions  int
+	Lapses       int
+	DueAt        time.Time
+}
+
+// NewState returns the state of a word that has never been reviewed
+func NewState() State {
+	return State{EaseFactor: DefaultEaseFactor}
+}
+
+// Grade is the self-assessed difficulty of a recall, as sent by study activities
+type Grade string
+
+const (
+	GradeAgain Grade = "again"
+	GradeHard  Grade = "hard"
+	GradeGood  Grade = "good"
+	GradeEasy  Grade = "easy"
+)
+
+// Valid reports whether g is one of the known grades
+func (g Grade) Valid() bool {
+	switch g {
+	case GradeAgain, GradeHard, GradeGood, GradeEasy:
+		return true
+	}
+	return false
+}
+
+// Correct reports whether the grade counts as a successful recall
+func (g Grade) Correct() bool {
+	return g != GradeAgain
+}
+
+// Quality maps a grade onto the SM-2 scale
+func (g Grade) Quality() Quality {
+	switch g {
+	case GradeEasy:
+		return QualityEasy
+	case GradeGood:
+		return QualityGood
+	case GradeHard:
+		return QualityHard
+	default:
+		return QualityWrong
+	}
+}
+
+// QualityFromCorrect maps a plain correct/incorrect answer onto an SM-2 grade
+func QualityFromCorrect(correct bool) Quality {
+	if correct {
+		return QualityGood
+	}
+	return QualityWrong
+}
+
+// Review applies one review with the given quality at time now and returns
+// the updated state
+func Review(state State, quality Quality, now time.Time) State {
+	if quality < QualityBlackout {
+		quality = QualityBlackout
+	}
+	if quality > QualityEasy {
+		quality = QualityEasy
+	}
+	if state.EaseFactor == 0 {
+		state.EaseFactor = DefaultEaseFactor
+	}
+
+	if quality >= QualityHard {
+		switch state.Repetitions {
+		case 0:
+			state.IntervalDays = 1
+		case 1:
+			state.IntervalDays = 6
+		default:
+			state.IntervalDays = int(math.Round(float64(state.IntervalDays) * state.EaseFactor))
+		}
+		state.Repetitions++
+	} else {
+		state.Repetitions = 0
+		state.IntervalDays = 1
+		state.Lapses++
+	}
+
+	q := float64(QualityEasy - quality)
+	state.EaseFactor += 0.1 - q*(0.08+q*0.02)
+	if state.EaseFactor < MinEaseFactor {
+		state.EaseFactor = MinEaseFactor
+	}
+
+	state.DueAt = now.AddDate(0, 0, state.IntervalDays)
+	return state
+}
+
+// Urgency ranks how overdue a review is relative to its interval; a word one
+// day late on a one day interval is more urgent than one a day late on a
+// month long interval. Values below zero mean the word is not yet due.
+func Urgency(state State, now time.Time) float64 {
+	interval := float64(state.IntervalDays)
+	if interval < 1 {
+		interval = 1
+	}
+	return now.Sub(state.DueAt).Hours() / 24 / interval
+}
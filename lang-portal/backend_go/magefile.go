@@ -2,11 +2,47 @@
 package main
 
 import (
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
-	"github.com/magefile/mage/sh"
+	"strconv"
+	"github.com/mohawa/lang-portal/backend_go/internal/database"
+	"github.com/mohawa/lang-portal/backend_go/internal/exporter"
+	"github.com/mohawa/lang-portal/backend_go/internal/importer"
+	"github.com/mohawa/lang-portal/backend_go/internal/models"
+	"github.com/mohawa/lang-portal/backend_go/internal/services"
 )
 
+const (
+	migrationsDir = "db/migrations"
+	seedsDir      = "db/seeds"
+)
+
+// targetDatabase returns the database file that the migrate and seed tasks operate on,
+// following APP_ENV and defaulting to the production database like the other tasks
+func targetDatabase() string {
+	switch os.Getenv("APP_ENV") {
+	case "test":
+		return "db/data/words.test.db"
+	case "development":
+		return "db/data/words.dev.db"
+	default:
+		return "db/data/words.prod.db"
+	}
+}
+
+// migrateFile applies all pending migrations to the given database file
+func migrateFile(dbPath string) error {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	return database.Migrate(db, migrationsDir)
+}
+
 // Dbinit initializes the database with schema
 func Dbinit() error {
 	fmt.Println("Initializing production database...")
@@ -16,8 +52,8 @@ func Dbinit() error {
 		return fmt.Errorf("failed to create db directory: %v", err)
 	}
 
-	// Execute schema file
-	if err := sh.Run("sqlite3", "db/data/words.prod.db", ".read db/migrations/0001_init.sql"); err != nil {
+	// Apply migrations
+	if err := migrateFile("db/data/words.prod.db"); err != nil {
 		return fmt.Errorf("failed to initialize database: %v", err)
 	}
 
@@ -25,74 +61,60 @@ func Dbinit() error {
 	return nil
 }
 
-// Seed adds test data to the database
-func Seed() error {
-	fmt.Println("Seeding production database...")
-
-	// Test data SQL
-	seedSQL := []string{
-		// Groups
-		`INSERT INTO groups (id, name) VALUES (1, 'Basic Greetings');`,
-		`INSERT INTO groups (id, name) VALUES (2, 'Numbers');`,
-		
-		// Words
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (1, 'こんにちは', 'konnichiwa', 'hello');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (2, 'さようなら', 'sayounara', 'goodbye');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (3, 'おはよう', 'ohayou', 'good morning');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (4, '一', 'ichi', 'one');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (5, '二', 'ni', 'two');`,
-
-		// Study Activities
-		`INSERT INTO study_activities (id, name, thumbnail_url, description) 
-		 VALUES (1, 'Flashcards', '/images/flashcards.png', 'Practice words using flashcards');`,
-
-		// Study Sessions
-		`INSERT INTO study_sessions (id, group_id, created_at) 
-		 VALUES (1, 1, datetime('now', '-1 day'));`,
-		`INSERT INTO study_sessions (id, group_id, created_at) 
-		 VALUES (2, 2, datetime('now', '-2 hours'));`,
-
-		// Word Review Items
-		`INSERT INTO word_review_items (word_id, study_session_id, correct, created_at) 
-		 VALUES (1, 1, true, datetime('now', '-23.5 hours'));`,
-		`INSERT INTO word_review_items (word_id, study_session_id, correct, created_at) 
-		 VALUES (2, 1, false, datetime('now', '-23.4 hours'));`,
-		`INSERT INTO word_review_items (word_id, study_session_id, correct, created_at) 
-		 VALUES (3, 1, true, datetime('now', '-23.3 hours'));`,
-		`INSERT INTO word_review_items (word_id, study_session_id, correct, created_at) 
-		 VALUES (4, 2, true, datetime('now', '-1.5 hours'));`,
-		`INSERT INTO word_review_items (word_id, study_session_id, correct, created_at) 
-		 VALUES (5, 2, false, datetime('now', '-1 hour'));`,
-
-		// Link words to groups
-		`INSERT INTO words_groups (word_id, group_id) VALUES (1, 1);`,
-		`INSERT INTO words_groups (word_id, group_id) VALUES (2, 1);`,
-		`INSERT INTO words_groups (word_id, group_id) VALUES (3, 1);`,
-		`INSERT INTO words_groups (word_id, group_id) VALUES (4, 2);`,
-		`INSERT INTO words_groups (word_id, group_id) VALUES (5, 2);`,
-	}
-
-	// Write seed SQL to temporary file
-	tmpFile := "db/seed_temp.sql"
-	f, err := os.Create(tmpFile)
+// Migrate applies pending migrations from db/migrations (APP_ENV selects the database)
+func Migrate() error {
+	dbPath := targetDatabase()
+	fmt.Printf("Migrating %s...\n", dbPath)
+
+	if err := os.MkdirAll("db/data", 0755); err != nil {
+		return fmt.Errorf("failed to create db directory: %v", err)
+	}
+
+	if err := migrateFile(dbPath); err != nil {
+		return fmt.Errorf("failed to migrate database: %v", err)
+	}
+
+	fmt.Println("Database is up to date")
+	return nil
+}
+
+// Rollback reverts the most recently applied migration using its .down.sql file
+func Rollback() error {
+	dbPath := targetDatabase()
+	fmt.Printf("Rolling back last migration on %s...\n", dbPath)
+
+	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %v", err)
+		return fmt.Errorf("failed to open database: %v", err)
 	}
-	defer os.Remove(tmpFile)
+	defer db.Close()
 
-	for _, sql := range seedSQL {
-		if _, err := f.WriteString(sql + "\n"); err != nil {
-			return fmt.Errorf("failed to write seed SQL: %v", err)
-		}
+	if err := database.MigrateDown(db, migrationsDir, 1); err != nil {
+		return fmt.Errorf("failed to roll back migration: %v", err)
 	}
-	f.Close()
 
-	// Execute seed file
-	if err := sh.Run("sqlite3", "db/data/words.prod.db", ".read " + tmpFile); err != nil {
+	fmt.Println("Rollback complete")
+	return nil
+}
+
+// Seed imports the vocabulary and study activities listed in db/seeds/manifest.json
+func Seed() error {
+	dbPath := targetDatabase()
+	fmt.Printf("Seeding %s...\n", dbPath)
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	result, err := database.ImportSeeds(db, seedsDir)
+	if err != nil {
 		return fmt.Errorf("failed to seed database: %v", err)
 	}
 
-	fmt.Println("Production database seeded successfully")
+	fmt.Printf("Database seeded successfully: %d words created, %d updated, %d group links added\n",
+		result.WordsCreated, result.WordsUpdated, result.LinksCreated)
 	return nil
 }
 
@@ -113,75 +135,150 @@ func Testdb() error {
 	os.Remove(testDB)
 
 	// Initialize schema
-	if err := sh.Run("sqlite3", testDB, ".read db/migrations/0001_init.sql"); err != nil {
+	if err := migrateFile(testDB); err != nil {
 		return fmt.Errorf("failed to initialize test database: %v", err)
 	}
 
 	fmt.Println("Test database initialized, seeding data...")
 
-	// Test data SQL
-	seedSQL := []string{
-		// Groups
-		`INSERT INTO groups (id, name) VALUES (1, 'Basic Greetings');`,
-		`INSERT INTO groups (id, name) VALUES (2, 'Numbers');`,
-		
-		// Words
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (1, 'こんにちは', 'konnichiwa', 'hello');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (2, 'さようなら', 'sayounara', 'goodbye');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (3, 'おはよう', 'ohayou', 'good morning');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (4, '一', 'ichi', 'one');`,
-		`INSERT INTO words (id, japanese, romaji, english) VALUES (5, '二', 'ni', 'two');`,
-
-		// Study Activities
-		`INSERT INTO study_activities (id, name, thumbnail_url, description) 
-		 VALUES (1, 'Flashcards', '/images/flashcards.png', 'Practice words using flashcards');`,
-
-		// Study Sessions
-		`INSERT INTO study_sessions (id, group_id, created_at) 
-		 VALUES (1, 1, datetime('now', '-1 day'));`,
-		`INSERT INTO study_sessions (id, group_id, created_at) 
-		 VALUES (2, 2, datetime('now', '-2 hours'));`,
-
-		// Word Review Items
-		`INSERT INTO word_review_items (word_id, study_session_id, correct, created_at) 
-		 VALUES (1, 1, true, datetime('now', '-23.5 hours'));`,
-		`INSERT INTO word_review_items (word_id, study_session_id, correct, created_at) 
-		 VALUES (2, 1, false, datetime('now', '-23.4 hours'));`,
-		`INSERT INTO word_review_items (word_id, study_session_id, correct, created_at) 
-		 VALUES (3, 1, true, datetime('now', '-23.3 hours'));`,
-		`INSERT INTO word_review_items (word_id, study_session_id, correct, created_at) 
-		 VALUES (4, 2, true, datetime('now', '-1.5 hours'));`,
-		`INSERT INTO word_review_items (word_id, study_session_id, correct, created_at) 
-		 VALUES (5, 2, false, datetime('now', '-1 hour'));`,
-
-		// Link words to groups
-		`INSERT INTO words_groups (word_id, group_id) VALUES (1, 1);`,
-		`INSERT INTO words_groups (word_id, group_id) VALUES (2, 1);`,
-		`INSERT INTO words_groups (word_id, group_id) VALUES (3, 1);`,
-		`INSERT INTO words_groups (word_id, group_id) VALUES (4, 2);`,
-		`INSERT INTO words_groups (word_id, group_id) VALUES (5, 2);`,
-	}
-
-	// Write seed SQL to temporary file
-	tmpFile := "db/seed_temp.sql"
-	f, err := os.Create(tmpFile)
+	db, err := sql.Open("sqlite3", testDB)
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %v", err)
+		return fmt.Errorf("failed to open test database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := database.ImportSeeds(db, seedsDir); err != nil {
+		return fmt.Errorf("failed to seed test database: %v", err)
 	}
-	defer os.Remove(tmpFile)
 
-	for _, sql := range seedSQL {
-		if _, err := f.WriteString(sql + "\n"); err != nil {
-			return fmt.Errorf("failed to write seed SQL: %v", err)
+	fmt.Println("Test database setup complete with seed data")
+	return nil
+}
+
+// Import previews or commits a CSV, TSV or Anki (.apkg) vocabulary file into
+// the named group (APP_ENV selects the database). The format follows the file
+// extension. IMPORT_MAPPING takes a JSON object of source field to column,
+// IMPORT_HAS_HEADER=false reads CSV/TSV columns by number, IMPORT_ON_CONFLICT
+// is skip or update, and IMPORT_DRY_RUN=true only previews.
+func Import(path, group string) error {
+	dbPath := targetDatabase()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	options := services.ImportOptions{
+		Format:     importer.FormatFromFilename(path),
+		HasHeader:  os.Getenv("IMPORT_HAS_HEADER") != "false",
+		GroupName:  group,
+		OnConflict: os.Getenv("IMPORT_ON_CONFLICT"),
+		Commit:     os.Getenv("IMPORT_DRY_RUN") != "true",
+	}
+	if mapping := os.Getenv("IMPORT_MAPPING"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &options.Mapping); err != nil {
+			return fmt.Errorf("IMPORT_MAPPING must be a JSON object: %v", err)
 		}
 	}
-	f.Close()
 
-	// Execute seed file
-	if err := sh.Run("sqlite3", testDB, ".read " + tmpFile); err != nil {
-		return fmt.Errorf("failed to seed test database: %v", err)
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
 	}
+	defer db.Close()
+	database.DB = db
 
-	fmt.Println("Test database setup complete with seed data")
+	result, err := services.NewImportService().ImportWords(data, options)
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %v", path, err)
+	}
+
+	for _, row := range result.Rows {
+		if row.Status == models.ImportError || row.Status == models.ImportConflict {
+			fmt.Printf("row %d: %s: %s\n", row.Row, row.Status, row.Message)
+		}
+	}
+	verb := "Previewed"
+	if result.Committed {
+		verb = "Imported"
+	}
+	fmt.Printf("%s %d rows into %q in %s: %d new, %d duplicate, %d conflicting, %d errors\n",
+		verb, result.Summary.Total, result.GroupName, dbPath,
+		result.Summary.New, result.Summary.Duplicate, result.Summary.Conflict, result.Summary.Error)
+	return nil
+}
+
+// Export writes words or reviews (kind) to a file, in the format given by its
+// extension: .csv, .json or, for words, an Anki .apkg deck. EXPORT_GROUP_ID
+// limits the export to one group and EXPORT_USER_ID picks the learner whose
+// history is exported, by default the local one (APP_ENV selects the database).
+func Export(kind, path string) error {
+	dbPath := targetDatabase()
+
+	format := exporter.FormatFromFilename(path)
+	if format == "" {
+		return fmt.Errorf("cannot tell the export format from %s; use .csv, .json or .apkg", path)
+	}
+	if kind == "reviews" && format == exporter.FormatApkg {
+		return fmt.Errorf("reviews can only be exported as .csv or .json")
+	}
+	groupID := 0
+	if value := os.Getenv("EXPORT_GROUP_ID"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("EXPORT_GROUP_ID must be a number: %v", err)
+		}
+		groupID = id
+	}
+	userID := services.LocalUserID
+	if value := os.Getenv("EXPORT_USER_ID"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("EXPORT_USER_ID must be a number: %v", err)
+		}
+		userID = id
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	database.DB = db
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer file.Close()
+
+	count := 0
+	var writeErr error
+	switch kind {
+	case "words":
+		export, err := services.NewExportService().ExportWords(userID, groupID)
+		if err != nil {
+			return fmt.Errorf("failed to export words: %v", err)
+		}
+		count = len(export.Words)
+		writeErr = exporter.WriteWords(file, format, export)
+	case "reviews":
+		export, err := services.NewExportService().ExportReviews(userID, groupID)
+		if err != nil {
+			return fmt.Errorf("failed to export reviews: %v", err)
+		}
+		count = len(export.Reviews)
+		writeErr = exporter.WriteReviews(file, format, export)
+	default:
+		return fmt.Errorf("unknown export %q; use words or reviews", kind)
+	}
+	if writeErr != nil {
+		return fmt.Errorf("failed to write %s: %v", path, writeErr)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	fmt.Printf("Exported %d %s from %s to %s\n", count, kind, dbPath, path)
 	return nil
-} 
\ No newline at end of file
+}